@@ -20,6 +20,16 @@ func main() {
 			handlers.HandleIndex(w, r)
 		case "/api/calculate":
 			handlers.HandleCalculate(w, r)
+		case "/api/parallelism":
+			handlers.HandleParallelism(w, r)
+		case "/api/capacity":
+			handlers.HandleCapacity(w, r)
+		case "/api/serving":
+			handlers.HandleServing(w, r)
+		case "/api/alignment":
+			handlers.HandleAlignment(w, r)
+		case "/api/speculative":
+			handlers.HandleSpeculative(w, r)
 		case "/documentation":
 			handlers.HandleDocs(w, r)
 		default:
@@ -0,0 +1,178 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"math"
+)
+
+const (
+	CheckpointNone      = "none"
+	CheckpointSelective = "selective"
+	CheckpointFull      = "full"
+)
+
+// Per-layer activation bytes per sbh element (Korthikanti et al., 16-bit).
+const (
+	attentionActivationFactor = 11.0
+	scoresActivationFactor    = 5.0
+	normActivationFactor      = 4.0
+	inputActivationFactor     = 2.0
+)
+
+type ActivationOptions struct {
+	Checkpointing     string
+	FlashAttention    bool
+	FusedCrossEntropy bool
+}
+
+type ActivationBreakdown struct {
+	Attention       float64
+	AttentionScores float64
+	CrossAttention  float64
+	MLP             float64
+	SSM             float64
+	Norms           float64
+	Router          float64
+	PerLayer        float64
+	Logits          float64
+	Loss            float64
+	Components      float64
+	LayersStored    int
+	Total           float64
+	Removed         map[string]float64
+}
+
+func (b ActivationBreakdown) Format() map[string]string {
+	formatted := map[string]string{
+		"attention":        FormatMemory(b.Attention),
+		"attention_scores": FormatMemory(b.AttentionScores),
+		"mlp":              FormatMemory(b.MLP),
+		"norms":            FormatMemory(b.Norms),
+		"per_layer":        FormatMemory(b.PerLayer),
+	}
+	if b.Router > 0 {
+		formatted["router"] = FormatMemory(b.Router)
+	}
+	if b.SSM > 0 {
+		formatted["ssm"] = FormatMemory(b.SSM)
+	}
+	if b.CrossAttention > 0 {
+		formatted["cross_attention"] = FormatMemory(b.CrossAttention)
+	}
+	if b.Components > 0 {
+		formatted["components"] = FormatMemory(b.Components)
+	}
+	if b.Logits > 0 {
+		formatted["logits"] = FormatMemory(b.Logits)
+	}
+	if b.Loss > 0 {
+		formatted["loss"] = FormatMemory(b.Loss)
+	}
+	return formatted
+}
+
+func (b ActivationBreakdown) FormatRemoved() map[string]string {
+	removed := make(map[string]string, len(b.Removed))
+	for option, bytes := range b.Removed {
+		removed[option] = FormatMemory(bytes)
+	}
+	return removed
+}
+
+func activationScale(precision string) float64 {
+	if precision == "float32" {
+		return config.BytesPerParam("float32") / 2.0
+	}
+	return 1.0
+}
+
+// Inference keeps the heaviest layer; training sums all of them.
+func GetActivationBreakdown(spec ModelSpec, precision string, batchSize, seqLength int, training bool, opts ActivationOptions) ActivationBreakdown {
+	scale := activationScale(precision)
+	batchF := float64(batchSize)
+	seqF := float64(seqLength)
+	hiddenF := float64(spec.HiddenSize)
+	headsF := float64(spec.NumHeads)
+	sbh := batchF * seqF * hiddenF
+	attentionLayers := spec.CountBlocks(BlockAttention)
+
+	b := ActivationBreakdown{Removed: map[string]float64{}}
+	if attentionLayers > 0 {
+		b.Attention = attentionActivationFactor * sbh * scale
+		scores := scoresActivationFactor * headsF * seqF * seqF * batchF * scale
+		b.AttentionScores = scores
+		if opts.FlashAttention {
+			softmaxStats := headsF * seqF * batchF * config.BytesPerParam("float32")
+			b.AttentionScores = softmaxStats
+			b.Removed["flash_attention"] = scores - softmaxStats
+		} else if training && opts.Checkpointing == CheckpointSelective {
+			b.AttentionScores = 0
+			b.Removed["selective_checkpointing"] = scores
+		}
+	}
+	if spec.IsHybrid() {
+		b.SSM = mambaActivations(spec, sbh, batchF*seqF, scale)
+	}
+	b.CrossAttention = crossAttentionActivations(spec, batchSize, seqLength, scale, opts)
+	denseMLP := mlpActivationFactor * sbh * scale
+	moeMLP := denseMLP
+	if spec.CountBlocks(BlockMoE) > 0 {
+		moeMLP *= float64(spec.NumExpertsPerTok)
+		b.Router = GetRouterMemory(batchSize, seqLength, spec.HiddenSize, spec.NumExperts, spec.NumExpertsPerTok, precision)
+		b.MLP = moeMLP
+	} else if spec.CountBlocks(BlockMLP) > 0 {
+		b.MLP = denseMLP
+	}
+	b.Norms = normActivationFactor * sbh * scale
+	blockNorm := b.Norms / 2
+
+	layersTotal := 0.0
+	for _, layer := range spec.Layers() {
+		layerTotal := blockNorm
+		switch layer.Mixer {
+		case BlockAttention:
+			layerTotal += b.Attention + b.AttentionScores + b.CrossAttention
+		case BlockMamba:
+			layerTotal += b.SSM
+		}
+		switch layer.FeedForward {
+		case BlockMLP:
+			layerTotal += blockNorm + denseMLP
+		case BlockMoE:
+			layerTotal += blockNorm + moeMLP + b.Router
+		}
+		layersTotal += layerTotal
+		if layerTotal > b.PerLayer {
+			b.PerLayer = layerTotal
+		}
+	}
+	b.Logits, b.Loss = GetLogitsMemory(spec, batchSize, seqLength, training, opts.FusedCrossEntropy)
+	if training && opts.FusedCrossEntropy {
+		logits, loss := GetLogitsMemory(spec, batchSize, seqLength, training, false)
+		b.Removed["fused_cross_entropy"] = logits + loss - b.Logits - b.Loss
+	}
+
+	// Components finish before the decoder, so at inference only the larger part counts.
+	b.Components = GetComponentActivations(spec, batchSize, training, opts)
+	if !training {
+		b.LayersStored = 1
+		b.Total = math.Max(b.PerLayer+b.Logits, b.Components)
+		return b
+	}
+	b.LayersStored = spec.NumLayers
+	b.Total = layersTotal
+	if opts.Checkpointing != CheckpointFull {
+		for option := range b.Removed {
+			if option != "fused_cross_entropy" {
+				b.Removed[option] *= float64(attentionLayers)
+			}
+		}
+	} else {
+		layerInputs := inputActivationFactor * sbh * scale * float64(spec.NumLayers)
+		fullTotal := layerInputs + b.PerLayer
+		b.Removed["full_checkpointing"] = b.Total - fullTotal
+		b.Total = fullTotal
+	}
+	b.Total += b.Logits + b.Loss + b.Components
+	return b
+}
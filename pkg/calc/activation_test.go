@@ -0,0 +1,108 @@
+package calc
+
+import "testing"
+
+func TestGetActivationBreakdown(t *testing.T) {
+	// Llama-2-7B at 2,048 tokens: sbh = 2048 × 4096, so one layer holds
+	// 11 + 19 + 4 = 34 sbh plus 5 × 32 heads × 2048² score bytes. Inference
+	// adds fp32 logits for the last position, training fp32 logits and
+	// softmax for all 2,048.
+	const (
+		sbh         = 2048 * 4096
+		scores      = 5 * 32 * 2048 * 2048
+		perLayer    = 34*sbh + scores
+		flashStat   = 32 * 2048 * 4
+		lastLogits  = 32000 * 4
+		trainLogits = 2 * 2048 * 32000 * 4
+	)
+	tests := []struct {
+		name     string
+		training bool
+		opts     ActivationOptions
+		want     float64
+		removed  string
+	}{
+		{"inference holds one layer", false, ActivationOptions{}, perLayer + lastLogits, ""},
+		{"inference with FlashAttention", false, ActivationOptions{FlashAttention: true}, 34*sbh + flashStat + lastLogits, "flash_attention"},
+		{"training stores every layer", true, ActivationOptions{Checkpointing: CheckpointNone}, 32*perLayer + trainLogits, ""},
+		{"selective drops the scores", true, ActivationOptions{Checkpointing: CheckpointSelective}, 32*34*sbh + trainLogits, "selective_checkpointing"},
+		// Layer inputs for all 32 layers plus one layer being recomputed.
+		{"full keeps layer inputs", true, ActivationOptions{Checkpointing: CheckpointFull}, 32*2*sbh + perLayer + trainLogits, "full_checkpointing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := GetActivationBreakdown(llama2_7B, "bfloat16", 1, 2048, tt.training, tt.opts)
+			if b.Total != tt.want {
+				t.Errorf("activations = %s, want %s", FormatMemory(b.Total), FormatMemory(tt.want))
+			}
+			if tt.removed != "" && b.Removed[tt.removed] <= 0 {
+				t.Errorf("expected %s to report saved memory, got %v", tt.removed, b.Removed)
+			}
+		})
+	}
+}
+
+func TestActivationScale(t *testing.T) {
+	// The fp32 logits do not scale with the compute dtype.
+	bf16 := GetActivationBreakdown(llama2_7B, "bfloat16", 1, 2048, false, ActivationOptions{})
+	tests := []struct {
+		precision string
+		want      float64
+	}{
+		{"float32", 2*bf16.PerLayer + bf16.Logits},
+		{"float16", bf16.Total},
+		{"int8", bf16.Total},
+		{"int4", bf16.Total},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			got := GetActivationBreakdown(llama2_7B, tt.precision, 1, 2048, false, ActivationOptions{})
+			if got.Total != tt.want {
+				t.Errorf("activations = %s, want %s", FormatMemory(got.Total), FormatMemory(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetLogitsMemory(t *testing.T) {
+	tests := []struct {
+		name         string
+		batch, seq   int
+		training     bool
+		fused        bool
+		scalarHead   bool
+		logits, loss float64
+	}{
+		// Only the last position of each of 4 sequences is projected.
+		{"inference", 4, 2048, false, false, false, 4 * 32000 * 4, 0},
+		{"training", 2, 2048, true, false, false, 2 * 2048 * 32000 * 4, 2 * 2048 * 32000 * 4},
+		// ⌈32000 / 4096⌉ = 8 chunks of 4,096 / 8 = 512 tokens.
+		{"fused", 2, 2048, true, true, false, 512 * 32000 * 4, 512 * 32000 * 4},
+		// 3,000 / 8 = 375 tokens rounds up to a 512-token chunk.
+		{"fused rounds chunks to a power of two", 1, 3000, true, true, false, 512 * 32000 * 4, 512 * 32000 * 4},
+		// A reward or value model scores through a one-wide head, so there are no vocab logits.
+		{"scalar head inference", 4, 2048, false, false, true, 0, 0},
+		{"scalar head training", 2, 2048, true, false, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := llama2_7B
+			spec.ScalarHead = tt.scalarHead
+			logits, loss := GetLogitsMemory(spec, tt.batch, tt.seq, tt.training, tt.fused)
+			if logits != tt.logits || loss != tt.loss {
+				t.Errorf("logits, loss = %.0f, %.0f; want %.0f, %.0f", logits, loss, tt.logits, tt.loss)
+			}
+		})
+	}
+}
+
+func TestFusedCrossEntropySavings(t *testing.T) {
+	opts := ActivationOptions{Checkpointing: CheckpointFull}
+	plain := GetActivationBreakdown(llama2_7B, "bfloat16", 2, 2048, true, opts)
+	opts.FusedCrossEntropy = true
+	fused := GetActivationBreakdown(llama2_7B, "bfloat16", 2, 2048, true, opts)
+	saved := plain.Total - fused.Total
+	if want := 2.0 * (4096 - 512) * 32000 * 4; saved != want || fused.Removed["fused_cross_entropy"] != want {
+		t.Errorf("fused saves %.0f B (reported %.0f), want %.0f", saved, fused.Removed["fused_cross_entropy"], want)
+	}
+}
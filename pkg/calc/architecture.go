@@ -0,0 +1,125 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"math"
+)
+
+const (
+	BlockAttention = "attention"
+	BlockMamba     = "mamba"
+	BlockMLP       = "mlp"
+	BlockMoE       = "moe"
+	BlockNone      = "none"
+)
+
+// Mamba activation bytes per sbh element: the block input, and per inner channel what the scan keeps for backward.
+const (
+	mambaInputActivationFactor   = 2.0
+	mambaChannelActivationFactor = 14.0
+)
+
+type LayerBlocks struct {
+	Mixer       string
+	FeedForward string
+}
+
+// SSMConfig sizes a Mamba mixer; DTRank defaults to ⌈hidden / 16⌉.
+type SSMConfig struct {
+	DState int
+	DConv  int
+	Expand int
+	DTRank int
+}
+
+func (m ModelSpec) Layers() []LayerBlocks {
+	if len(m.Blocks) == m.NumLayers {
+		return m.Blocks
+	}
+	ffn := BlockMLP
+	if m.IsMoE() {
+		ffn = BlockMoE
+	}
+	layers := make([]LayerBlocks, m.NumLayers)
+	for i := range layers {
+		layers[i] = LayerBlocks{Mixer: BlockAttention, FeedForward: ffn}
+	}
+	return layers
+}
+
+func (m ModelSpec) CountBlocks(block string) int {
+	count := 0
+	for _, layer := range m.Layers() {
+		if layer.Mixer == block || layer.FeedForward == block {
+			count++
+		}
+	}
+	return count
+}
+
+// projectionCopies returns the instances of a projection and the layers they span.
+func (m ModelSpec) projectionCopies(module string) (float64, float64) {
+	switch module {
+	case "gate_proj", "up_proj", "down_proj":
+		dense, moe := m.CountBlocks(BlockMLP), m.CountBlocks(BlockMoE)
+		return float64(dense + moe*m.NumExperts), float64(dense + moe)
+	}
+	layers := float64(m.CountBlocks(BlockAttention))
+	return layers, layers
+}
+
+// NormCount is one norm in front of every block plus the final norm.
+func (m ModelSpec) NormCount() int {
+	count := 1
+	for _, layer := range m.Layers() {
+		count++
+		if layer.FeedForward != BlockNone {
+			count++
+		}
+	}
+	return count
+}
+
+func (m ModelSpec) IsHybrid() bool {
+	return m.CountBlocks(BlockMamba) > 0
+}
+
+func (m ModelSpec) SSMInnerSize() int {
+	return m.SSM.Expand * m.HiddenSize
+}
+
+func (m ModelSpec) SSMTimeStepRank() int {
+	if m.SSM.DTRank > 0 {
+		return m.SSM.DTRank
+	}
+	return int(math.Ceil(float64(m.HiddenSize) / 16))
+}
+
+func GetSSMParams(spec ModelSpec) float64 {
+	hidden := float64(spec.HiddenSize)
+	inner := float64(spec.SSMInnerSize())
+	state := float64(spec.SSM.DState)
+	dtRank := float64(spec.SSMTimeStepRank())
+	inProj := hidden * 2 * inner
+	conv := inner*float64(spec.SSM.DConv) + inner
+	xProj := inner * (dtRank + 2*state)
+	dtProj := dtRank*inner + inner
+	return inProj + conv + xProj + dtProj + inner*state + inner + inner*hidden
+}
+
+func GetSSMState(spec ModelSpec, batchSize int, precision string) (float64, float64) {
+	layers := float64(spec.CountBlocks(BlockMamba))
+	if layers == 0 {
+		return 0, 0
+	}
+	bytes := config.BytesPerParam(config.UnquantizedDtype) * activationScale(precision)
+	perSequence := float64(batchSize) * float64(spec.SSMInnerSize()) * layers * bytes
+	ssm := perSequence * float64(spec.SSM.DState)
+	conv := perSequence * float64(spec.SSM.DConv-1)
+	return ssm, conv
+}
+
+func mambaActivations(spec ModelSpec, sbh, tokens, scale float64) float64 {
+	channels := mambaInputActivationFactor + mambaChannelActivationFactor*float64(spec.SSM.Expand)
+	return (channels*sbh + 2*2*tokens*float64(spec.SSM.DState)) * scale
+}
@@ -0,0 +1,93 @@
+package calc
+
+import "testing"
+
+// mamba130m is state-spaces/mamba-130m: 24 Mamba mixers with no MLPs and
+// tied 50,280-token embeddings.
+var mamba130m = ModelSpec{
+	VocabSize:         50280,
+	HiddenSize:        768,
+	NumLayers:         24,
+	TieWordEmbeddings: true,
+	SSM:               SSMConfig{DState: 16, DConv: 4, Expand: 2},
+	Blocks:            repeatBlocks(24, LayerBlocks{Mixer: BlockMamba, FeedForward: BlockNone}),
+}
+
+func repeatBlocks(n int, block LayerBlocks) []LayerBlocks {
+	blocks := make([]LayerBlocks, n)
+	for i := range blocks {
+		blocks[i] = block
+	}
+	return blocks
+}
+
+func TestGetSSMParams(t *testing.T) {
+	// d_inner 1536 and dt_rank ⌈768 / 16⌉ = 48: in_proj 768 × 3072, conv
+	// 1536 × 4 + 1536, x_proj 1536 × (48 + 32), dt_proj 48 × 1536 + 1536,
+	// A 1536 × 16, D 1536 and out_proj 1536 × 768.
+	const want = 2359296 + 7680 + 122880 + 75264 + 24576 + 1536 + 1179648
+	if got := GetSSMParams(mamba130m); got != want {
+		t.Errorf("SSM params = %.0f, want %d", got, want)
+	}
+	// 24 mixers, the embeddings and 25 norms: the published 129.1M.
+	if got := CountParameters(mamba130m).Total; got != 24*want+50280*768+25*768 {
+		t.Errorf("total params = %.0f, want %d", got, 24*want+50280*768+25*768)
+	}
+}
+
+func TestGetSSMState(t *testing.T) {
+	// 1536 channels × 24 layers × 2 bytes per sequence, times d_state 16
+	// for the scan and d_conv − 1 = 3 for the convolution.
+	tests := []struct {
+		precision string
+		batch     int
+		ssm, conv float64
+	}{
+		{"bfloat16", 1, 1536 * 24 * 2 * 16, 1536 * 24 * 2 * 3},
+		{"bfloat16", 8, 8 * 1536 * 24 * 2 * 16, 8 * 1536 * 24 * 2 * 3},
+		{"float32", 1, 1536 * 24 * 4 * 16, 1536 * 24 * 4 * 3},
+		// Quantized weights keep a 16-bit state.
+		{"int4", 1, 1536 * 24 * 2 * 16, 1536 * 24 * 2 * 3},
+	}
+	for _, tt := range tests {
+		ssm, conv := GetSSMState(mamba130m, tt.batch, tt.precision)
+		if ssm != tt.ssm || conv != tt.conv {
+			t.Errorf("%s batch %d: state = %.0f + %.0f, want %.0f + %.0f", tt.precision, tt.batch, ssm, conv, tt.ssm, tt.conv)
+		}
+	}
+	if ssm, conv := GetSSMState(llama3_8B, 1, "bfloat16"); ssm != 0 || conv != 0 {
+		t.Errorf("transformer state = %.0f + %.0f, want none", ssm, conv)
+	}
+}
+
+func TestHybridBlocks(t *testing.T) {
+	// A Jamba-style stack: attention in one layer of every 8, MoE in every
+	// other layer.
+	spec := llama3_8B
+	spec.NumExperts, spec.NumExpertsPerTok = 16, 2
+	spec.SSM = SSMConfig{DState: 16, DConv: 4, Expand: 2}
+	spec.Blocks = make([]LayerBlocks, 32)
+	for i := range spec.Blocks {
+		spec.Blocks[i] = LayerBlocks{Mixer: BlockMamba, FeedForward: BlockMLP}
+		if i%8 == 4 {
+			spec.Blocks[i].Mixer = BlockAttention
+		}
+		if i%2 == 1 {
+			spec.Blocks[i].FeedForward = BlockMoE
+		}
+	}
+	counts := map[string]int{BlockAttention: 4, BlockMamba: 28, BlockMLP: 16, BlockMoE: 16}
+	for block, want := range counts {
+		if got := spec.CountBlocks(block); got != want {
+			t.Errorf("%s layers = %d, want %d", block, got, want)
+		}
+	}
+	// Only the 4 attention layers cache keys and values.
+	if got, want := GetKVCacheBreakdown(spec, 1, 4096, "bfloat16").Total, 4096*4*2*8*128*2.0; got != want {
+		t.Errorf("KV cache = %.0f B, want %.0f B", got, want)
+	}
+	// 14 idle experts in each of the 16 MoE layers.
+	if got, want := GetActiveParams(spec), spec.TotalParams()-16*14*3*4096*14336.0; got != want {
+		t.Errorf("active params = %.0f, want %.0f", got, want)
+	}
+}
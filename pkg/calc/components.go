@@ -0,0 +1,143 @@
+package calc
+
+import "compute-gauge/pkg/config"
+
+const (
+	ComponentEncoder = "encoder"
+	ComponentVision  = "vision"
+)
+
+// Component is an encoder or vision tower; ExtraParams covers what its layer stack does not.
+type Component struct {
+	Name          string
+	Spec          ModelSpec
+	Precision     string
+	Tokens        int
+	ExtraParams   float64
+	Trainable     bool
+	CrossAttended bool
+}
+
+// NewEncoderComponent shares the decoder's token embeddings, as T5 does.
+func NewEncoderComponent(spec ModelSpec, precision string, sourceTokens int, trainable bool) Component {
+	return Component{
+		Name:          ComponentEncoder,
+		Spec:          spec,
+		Precision:     precision,
+		Tokens:        sourceTokens,
+		Trainable:     trainable,
+		CrossAttended: true,
+	}
+}
+
+func ImagePatches(imageSize, patchSize int) int {
+	if patchSize <= 0 {
+		return 0
+	}
+	side := imageSize / patchSize
+	return side * side
+}
+
+// NewVisionComponent is a ViT tower plus a LLaVA-style two-layer MLP projector.
+func NewVisionComponent(spec ModelSpec, precision string, imageSize, patchSize, numImages, hiddenSize int, trainable bool) Component {
+	patches := float64(ImagePatches(imageSize, patchSize))
+	vision := float64(spec.HiddenSize)
+	text := float64(hiddenSize)
+	patchEmbedding := 3*float64(patchSize*patchSize)*vision + vision
+	positions := (patches + 1) * vision
+	projector := vision*text + text + text*text + text
+	return Component{
+		Name:        ComponentVision,
+		Spec:        spec,
+		Precision:   precision,
+		Tokens:      numImages * int(patches),
+		ExtraParams: patchEmbedding + positions + vision + projector,
+		Trainable:   trainable,
+	}
+}
+
+func (c Component) Params() float64 {
+	return CountParameters(c.Spec).Total + c.ExtraParams
+}
+
+func (c Component) Weights() float64 {
+	return c.Params() * config.BytesPerParam(c.Precision)
+}
+
+func GetComponentParams(spec ModelSpec, trainableOnly bool) float64 {
+	params := 0.0
+	for _, c := range spec.Components {
+		if !trainableOnly || c.Trainable {
+			params += c.Params()
+		}
+	}
+	return params
+}
+
+func GetComponentWeights(spec ModelSpec) float64 {
+	weights := 0.0
+	for _, c := range spec.Components {
+		weights += c.Weights()
+	}
+	return weights
+}
+
+func (m ModelSpec) crossAttentionSource() (Component, bool) {
+	for _, c := range m.Components {
+		if c.CrossAttended {
+			return c, true
+		}
+	}
+	return Component{}, false
+}
+
+func GetCrossAttentionParams(spec ModelSpec) float64 {
+	source, ok := spec.crossAttentionSource()
+	if !ok {
+		return 0
+	}
+	hidden := float64(spec.HiddenSize)
+	qDim := float64(spec.NumHeads * spec.HeadDim())
+	kvDim := float64(spec.NumKVHeads * spec.HeadDim())
+	perLayer := hidden*qDim + 2*float64(source.Spec.HiddenSize)*kvDim + qDim*hidden + hidden
+	return perLayer * float64(spec.CountBlocks(BlockAttention))
+}
+
+// GetCrossAttentionKV is the encoder output every decoder layer projects once at prefill.
+func GetCrossAttentionKV(spec ModelSpec, batchSize int, dtype string) float64 {
+	source, ok := spec.crossAttentionSource()
+	if !ok {
+		return 0
+	}
+	return GetLayerKVCache(spec, batchSize, source.Tokens, spec.CountBlocks(BlockAttention), 1, dtype)
+}
+
+func crossAttentionActivations(spec ModelSpec, batchSize, seqLength int, scale float64, opts ActivationOptions) float64 {
+	source, ok := spec.crossAttentionSource()
+	if !ok {
+		return 0
+	}
+	batchF := float64(batchSize)
+	seqF := float64(seqLength)
+	headsF := float64(spec.NumHeads)
+	sbh := batchF * seqF * float64(spec.HiddenSize)
+	scores := scoresActivationFactor * headsF * seqF * float64(source.Tokens) * batchF * scale
+	if opts.FlashAttention {
+		scores = headsF * seqF * batchF * config.BytesPerParam("float32")
+	}
+	return attentionActivationFactor*sbh*scale + scores
+}
+
+// GetComponentActivations holds a single layer for frozen components.
+func GetComponentActivations(spec ModelSpec, batchSize int, training bool, opts ActivationOptions) float64 {
+	opts.FusedCrossEntropy = false
+	total := 0.0
+	for _, c := range spec.Components {
+		if c.Tokens == 0 {
+			continue
+		}
+		b := GetActivationBreakdown(c.Spec, c.Precision, batchSize, c.Tokens, training && c.Trainable, opts)
+		total += b.Total
+	}
+	return total
+}
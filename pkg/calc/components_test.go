@@ -0,0 +1,85 @@
+package calc
+
+import "testing"
+
+// clipL336 is the CLIP ViT-L/14 tower at 336 px used by LLaVA-1.5.
+var clipL336 = ModelSpec{HiddenSize: 1024, IntermediateSize: 4096, NumLayers: 24, NumHeads: 16, NumKVHeads: 16, HiddenAct: "gelu", AttentionBias: true, MLPBias: true}
+
+func TestImagePatches(t *testing.T) {
+	tests := []struct {
+		image, patch, want int
+	}{
+		{336, 14, 576},
+		{224, 16, 196},
+		{224, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := ImagePatches(tt.image, tt.patch); got != tt.want {
+			t.Errorf("ImagePatches(%d, %d) = %d, want %d", tt.image, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestNewVisionComponent(t *testing.T) {
+	c := NewVisionComponent(clipL336, "bfloat16", 336, 14, 2, 4096, false)
+	if c.Tokens != 2*576 {
+		t.Errorf("tokens = %d, want %d", c.Tokens, 2*576)
+	}
+	// Patch embedding 3 × 14² × 1024 + 1024, 577 positions × 1024, the
+	// pre-norm, and a 1024 → 4096 → 4096 projector of 20,979,712.
+	const want = 603136 + 590848 + 1024 + 20979712
+	if c.ExtraParams != want {
+		t.Errorf("extra params = %.0f, want %d", c.ExtraParams, want)
+	}
+	if got := c.Weights(); got != (CountParameters(clipL336).Total+want)*2 {
+		t.Errorf("weights = %.0f B, want %.0f B", got, (CountParameters(clipL336).Total+want)*2)
+	}
+}
+
+func TestCrossAttention(t *testing.T) {
+	// A T5-small-shaped decoder: 6 layers of 8 × 64 heads reading a
+	// 1,024-token encoder output.
+	encoder := ModelSpec{HiddenSize: 512, IntermediateSize: 2048, NumLayers: 6, NumHeads: 8, NumKVHeads: 8}
+	decoder := encoder
+	decoder.Components = []Component{NewEncoderComponent(encoder, "bfloat16", 1024, true)}
+	// q, k, v and o are 512 × 512 each, plus the block's norm.
+	if got, want := GetCrossAttentionParams(decoder), 6*(4*512*512+512.0); got != want {
+		t.Errorf("cross-attention params = %.0f, want %.0f", got, want)
+	}
+	// 2 × 1024 tokens × 6 layers × 512 × 2 bytes, whatever the output length.
+	if got, want := GetCrossAttentionKV(decoder, 1, "bfloat16"), 2*1024*6*512*2.0; got != want {
+		t.Errorf("cross-attention KV = %.0f B, want %.0f B", got, want)
+	}
+	if got := GetCrossAttentionKV(encoder, 1, "bfloat16"); got != 0 {
+		t.Errorf("decoder-only cross-attention KV = %.0f B, want 0", got)
+	}
+}
+
+func TestGetComponentActivations(t *testing.T) {
+	spec := llama2_7B
+	frozen := NewVisionComponent(clipL336, "bfloat16", 336, 14, 1, 4096, false)
+	trainable := frozen
+	trainable.Trainable = true
+	opts := ActivationOptions{FlashAttention: true}
+	layer := GetActivationBreakdown(clipL336, "bfloat16", 1, 576, false, opts).Total
+	all := GetActivationBreakdown(clipL336, "bfloat16", 1, 576, true, opts).Total
+	tests := []struct {
+		name      string
+		component Component
+		training  bool
+		want      float64
+	}{
+		{"inference", frozen, false, layer},
+		// A frozen tower runs without autograd and keeps one layer.
+		{"frozen in training", frozen, true, layer},
+		{"trainable in training", trainable, true, all},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec.Components = []Component{tt.component}
+			if got := GetComponentActivations(spec, 1, tt.training, opts); got != tt.want {
+				t.Errorf("activations = %s, want %s", FormatMemory(got), FormatMemory(tt.want))
+			}
+		})
+	}
+}
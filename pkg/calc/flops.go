@@ -0,0 +1,23 @@
+package calc
+
+// GetTrainingFlopsPerToken follows the PaLM 6N + 12·L·s·d_attn accounting, plus recomputation.
+func GetTrainingFlopsPerToken(spec ModelSpec, seqLength int, opts TrainingOptions) float64 {
+	params := flopParams(spec)
+	attention := GetAttentionFlopsPerKV(spec) * float64(seqLength)
+
+	forward := 2*params + attention
+	backward := 4*params + 2*attention
+	if opts.IsLoRA() {
+		backward = 2*params + 2*attention + 4*opts.TrainableParams(spec)
+	}
+	flops := forward + backward
+	switch opts.Activation.Checkpointing {
+	case CheckpointFull:
+		flops += forward
+	case CheckpointSelective:
+		if !opts.Activation.FlashAttention {
+			flops += attention
+		}
+	}
+	return flops
+}
@@ -0,0 +1,33 @@
+package calc
+
+import "testing"
+
+func TestGetTrainingFlopsPerToken(t *testing.T) {
+	// Llama-2-7B at 2,048 tokens: 6,607,343,616 non-embedding parameters and
+	// 4 × 32 layers × 4096 × 2048 attention FLOPs per forward pass.
+	const (
+		params    = 6607343616.0
+		attention = 1073741824.0
+		forward   = 2*params + attention
+		base      = 6*params + 3*attention
+	)
+	tests := []struct {
+		name string
+		opts TrainingOptions
+		want float64
+	}{
+		{"no checkpointing", TrainingOptions{}, base},
+		{"full checkpointing reruns the forward pass", TrainingOptions{Activation: ActivationOptions{Checkpointing: CheckpointFull}}, base + forward},
+		{"selective recomputes the scores", TrainingOptions{Activation: ActivationOptions{Checkpointing: CheckpointSelective}}, base + attention},
+		{"FlashAttention already recomputes them", TrainingOptions{Activation: ActivationOptions{Checkpointing: CheckpointSelective, FlashAttention: true}}, base},
+		// Frozen weights skip their weight gradients; r16 q,v adapters hold 8,388,608 parameters.
+		{"LoRA", TrainingOptions{Method: FineTuneLoRA, LoRARank: 16, LoRATargets: DefaultLoRATargets}, 4*params + 3*attention + 4*8388608},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetTrainingFlopsPerToken(llama2_7B, 2048, tt.opts); got != tt.want {
+				t.Errorf("training FLOPs per token = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"fmt"
+)
+
+// KVCacheFormat stores integer elements with one scale per GroupSize elements.
+type KVCacheFormat struct {
+	Bits       float64
+	GroupSize  int
+	ScaleBytes float64
+}
+
+var KVCacheFormats = map[string]KVCacheFormat{
+	"float32":  {Bits: 32},
+	"float16":  {Bits: 16},
+	"bfloat16": {Bits: 16},
+	"fp8_e4m3": {Bits: 8},
+	"fp8_e5m2": {Bits: 8},
+	"int8":     {Bits: 8, GroupSize: 64, ScaleBytes: 2},
+	"int4":     {Bits: 4, GroupSize: 32, ScaleBytes: 4},
+}
+
+func (f KVCacheFormat) BytesPerElement() float64 {
+	bytes := f.Bits / 8
+	if f.GroupSize > 0 {
+		bytes += f.ScaleBytes / float64(f.GroupSize)
+	}
+	return bytes
+}
+
+type KVCacheOptions struct {
+	Dtype           string
+	OffloadFraction float64
+}
+
+func (o KVCacheOptions) WithDefaults(precision string) KVCacheOptions {
+	if o.Dtype == "" {
+		o.Dtype = precision
+		if _, ok := KVCacheFormats[precision]; !ok {
+			o.Dtype = config.UnquantizedDtype
+		}
+	}
+	return o
+}
+
+func (o KVCacheOptions) Split(kvCache float64) (float64, float64) {
+	host := kvCache * o.OffloadFraction
+	return kvCache - host, host
+}
+
+const (
+	LayerFullAttention    = "full_attention"
+	LayerSlidingAttention = "sliding_attention"
+)
+
+// KVCacheBreakdown splits global and sliding-window layers; other layers cache nothing.
+type KVCacheBreakdown struct {
+	SlidingWindow  int
+	LocalLayers    int
+	GlobalLayers   int
+	LocalPerLayer  float64
+	GlobalPerLayer float64
+	Total          float64
+	Saved          float64
+}
+
+func (b KVCacheBreakdown) Format() map[string]string {
+	return map[string]string{
+		"sliding_window":          fmt.Sprintf("%d", b.SlidingWindow),
+		"local_layers":            fmt.Sprintf("%d", b.LocalLayers),
+		"global_layers":           fmt.Sprintf("%d", b.GlobalLayers),
+		"local_per_layer":         FormatMemory(b.LocalPerLayer),
+		"global_per_layer":        FormatMemory(b.GlobalPerLayer),
+		"saved_by_sliding_window": FormatMemory(b.Saved),
+	}
+}
+
+// IsSlidingLayer treats every layer as local without LayerTypes.
+func (m ModelSpec) IsSlidingLayer(i int) bool {
+	if m.SlidingWindow <= 0 {
+		return false
+	}
+	if len(m.LayerTypes) == 0 {
+		return true
+	}
+	return i < len(m.LayerTypes) && m.LayerTypes[i] == LayerSlidingAttention
+}
+
+func GetLayerKVCache(spec ModelSpec, batchSize, seqLength, numLayers, tp int, dtype string) float64 {
+	if format, ok := KVCacheFormats[dtype]; ok {
+		return float64(batchSize) * float64(seqLength) * float64(numLayers) * spec.KVWidth(tp) * format.BytesPerElement()
+	}
+	return 0
+}
+
+func GetKVCacheBreakdown(spec ModelSpec, batchSize, seqLength int, dtype string) KVCacheBreakdown {
+	b := KVCacheBreakdown{SlidingWindow: spec.SlidingWindow}
+	localSeq := seqLength
+	if spec.SlidingWindow > 0 && spec.SlidingWindow < seqLength {
+		localSeq = spec.SlidingWindow
+	}
+	b.GlobalPerLayer = GetLayerKVCache(spec, batchSize, seqLength, 1, 1, dtype)
+	b.LocalPerLayer = GetLayerKVCache(spec, batchSize, localSeq, 1, 1, dtype)
+	for i, layer := range spec.Layers() {
+		if layer.Mixer != BlockAttention {
+			continue
+		}
+		if spec.IsSlidingLayer(i) {
+			b.LocalLayers++
+		} else {
+			b.GlobalLayers++
+		}
+	}
+	b.Total = float64(b.LocalLayers)*b.LocalPerLayer + float64(b.GlobalLayers)*b.GlobalPerLayer
+	b.Saved = float64(b.LocalLayers+b.GlobalLayers)*b.GlobalPerLayer - b.Total
+	return b
+}
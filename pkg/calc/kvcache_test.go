@@ -0,0 +1,175 @@
+package calc
+
+import (
+	"math"
+	"testing"
+)
+
+const gib = 1024 * 1024 * 1024
+
+func approxEqual(got, want, tolerance float64) bool {
+	if want == 0 {
+		return got == 0
+	}
+	return math.Abs(got-want)/math.Abs(want) <= tolerance
+}
+
+func TestGetKVCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		batchSize  int
+		seqLength  int
+		numLayers  int
+		numKVHeads int
+		headDim    int
+		dtype      string
+		want       float64
+	}{
+		// 2 × 32 layers × 32 heads × 128 × 2 B = 512 KiB per token.
+		{"Llama-2-7B MHA", 1, 4096, 32, 32, 128, "float16", 2 * gib},
+		// 2 × 80 layers × 8 KV heads × 128 × 2 B = 320 KiB per token.
+		{"Llama-3-70B GQA", 1, 8192, 80, 8, 128, "bfloat16", 2.5 * gib},
+		{"Llama-3-70B GQA batch 4", 4, 8192, 80, 8, 128, "bfloat16", 10 * gib},
+		// Falcon-7B: one KV head of 64, so 2 × 32 × 64 × 2 B = 8 KiB per token.
+		{"Falcon-7B MQA", 1, 2048, 32, 1, 64, "bfloat16", 16 * 1024 * 1024},
+		{"unknown dtype", 1, 2048, 32, 8, 128, "float8", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetKVCache(tt.batchSize, tt.seqLength, tt.numLayers, tt.numKVHeads, tt.headDim, tt.dtype)
+			if !approxEqual(got, tt.want, 1e-9) {
+				t.Errorf("KV cache = %s, want %s", FormatMemory(got), FormatMemory(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetHeadDim(t *testing.T) {
+	tests := []struct {
+		hiddenSize, numHeads, want int
+	}{
+		{4096, 32, 128},
+		{8192, 64, 128},
+		{4544, 71, 64},
+		{4096, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := GetHeadDim(tt.hiddenSize, tt.numHeads); got != tt.want {
+			t.Errorf("GetHeadDim(%d, %d) = %d, want %d", tt.hiddenSize, tt.numHeads, got, tt.want)
+		}
+	}
+}
+
+func TestKVCacheFormatBytesPerElement(t *testing.T) {
+	tests := []struct {
+		dtype string
+		want  float64
+	}{
+		{"float32", 4},
+		{"bfloat16", 2},
+		{"fp8_e4m3", 1},
+		// One fp16 scale per 64 elements.
+		{"int8", 1 + 2.0/64},
+		// An fp16 scale and zero point per 32 elements.
+		{"int4", 0.5 + 4.0/32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dtype, func(t *testing.T) {
+			if got := KVCacheFormats[tt.dtype].BytesPerElement(); got != tt.want {
+				t.Errorf("bytes per element = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKVCacheOffload(t *testing.T) {
+	// Llama-3-70B at 8,192 tokens holds 2.5 GiB of bf16 KV cache.
+	kv := KVCacheOptions{OffloadFraction: 0.25}.WithDefaults("bfloat16")
+	if kv.Dtype != "bfloat16" {
+		t.Errorf("dtype = %s, want the weight precision", kv.Dtype)
+	}
+	results, _ := CalculateInferenceMemory(llama3_70B, "bfloat16", 1, 8192, ActivationOptions{}, kv)
+	formatted := results.Format()
+	if formatted["kv_cache"] != "2.50 GB" || formatted["kv_cache_gpu"] != "1.88 GB" || formatted["kv_cache_host"] != "640.00 MB" {
+		t.Errorf("KV cache %s split into %s GPU and %s host, want 2.50 GB, 1.88 GB and 640.00 MB",
+			formatted["kv_cache"], formatted["kv_cache_gpu"], formatted["kv_cache_host"])
+	}
+	fp8, _ := CalculateInferenceMemory(llama3_70B, "bfloat16", 1, 8192, ActivationOptions{}, KVCacheOptions{Dtype: "fp8_e4m3"})
+	if got := FormatMemory(fp8.KVCache); got != "1.25 GB" {
+		t.Errorf("fp8 KV cache = %s, want 1.25 GB", got)
+	}
+}
+
+func TestGetKVCacheBreakdown(t *testing.T) {
+	// Mistral-7B shape at 32k tokens: one layer caches 2 × 32768 × 8 × 128
+	// bf16 values = 128 MiB, or 16 MiB at a 4k window.
+	mistral := ModelSpec{HiddenSize: 4096, NumLayers: 32, NumHeads: 32, NumKVHeads: 8}
+	const mib = 1024 * 1024
+	tests := []struct {
+		name          string
+		window        int
+		layerTypes    []string
+		local, global int
+		total         float64
+	}{
+		{"no window", 0, nil, 0, 32, 32 * 128 * mib},
+		{"every layer local", 4096, nil, 32, 0, 32 * 16 * mib},
+		{"alternating", 4096, alternatingLayers(32), 16, 16, 16*16*mib + 16*128*mib},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := mistral
+			spec.SlidingWindow = tt.window
+			spec.LayerTypes = tt.layerTypes
+			b := GetKVCacheBreakdown(spec, 1, 32768, "bfloat16")
+			if b.LocalLayers != tt.local || b.GlobalLayers != tt.global {
+				t.Errorf("layers = %d local, %d global; want %d, %d", b.LocalLayers, b.GlobalLayers, tt.local, tt.global)
+			}
+			if b.Total != tt.total || b.Total+b.Saved != 32*128*mib {
+				t.Errorf("KV cache = %s, saved %s; want %s", FormatMemory(b.Total), FormatMemory(b.Saved), FormatMemory(tt.total))
+			}
+		})
+	}
+}
+
+// deepseekV2Lite has DeepSeek-V2-Lite's attention: no query bottleneck and
+// a 512 + 64 element latent per token.
+var deepseekV2Lite = ModelSpec{
+	HiddenSize:    2048,
+	NumLayers:     27,
+	NumHeads:      16,
+	NumKVHeads:    16,
+	KVLoRARank:    512,
+	QKRopeHeadDim: 64,
+	QKNopeHeadDim: 128,
+	VHeadDim:      128,
+}
+
+func TestGetLayerKVCache(t *testing.T) {
+	tests := []struct {
+		name string
+		spec ModelSpec
+		tp   int
+		want float64
+	}{
+		// 4,096 tokens × 27 layers × 576 latent elements × 2 bytes.
+		{"MLA latent", deepseekV2Lite, 1, 4096 * 27 * 576 * 2},
+		// Every rank keeps the whole latent.
+		{"MLA latent at TP=8", deepseekV2Lite, 8, 4096 * 27 * 576 * 2},
+		// 4,096 × 32 layers × 2 × 8 KV heads × 128 × 2 bytes.
+		{"GQA", llama3_8B, 1, 4096 * 32 * 2 * 8 * 128 * 2},
+		{"GQA at TP=8", llama3_8B, 8, 4096 * 32 * 2 * 1 * 128 * 2},
+		// Beyond 8 ranks the single KV head is replicated.
+		{"GQA at TP=16", llama3_8B, 16, 4096 * 32 * 2 * 1 * 128 * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetLayerKVCache(tt.spec, 1, 4096, tt.spec.NumLayers, tt.tp, "bfloat16"); got != tt.want {
+				t.Errorf("KV cache = %.0f B, want %.0f B", got, tt.want)
+			}
+		})
+	}
+	if got, want := GetKVCache(1, 4096, 32, 8, 128, "bfloat16"), GetLayerKVCache(llama3_8B, 1, 4096, 32, 1, "bfloat16"); got != want {
+		t.Errorf("GetKVCache = %.0f B, want %.0f B", got, want)
+	}
+}
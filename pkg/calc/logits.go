@@ -0,0 +1,25 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"math"
+)
+
+// GetLogitsMemory sizes the fp32 logits and loss softmax, chunked when the cross-entropy is fused.
+func GetLogitsMemory(spec ModelSpec, batchSize, seqLength int, training, fused bool) (float64, float64) {
+	if spec.ScalarHead {
+		return 0, 0
+	}
+	vocab := float64(spec.VocabSize)
+	fp32 := config.BytesPerParam("float32")
+	if !training {
+		return float64(batchSize) * vocab * fp32, 0
+	}
+	tokens := float64(batchSize) * float64(seqLength)
+	if fused && spec.HiddenSize > 0 {
+		chunks := math.Ceil(vocab / float64(spec.HiddenSize))
+		tokens = math.Min(tokens, math.Pow(2, math.Ceil(math.Log2(math.Ceil(tokens/chunks)))))
+	}
+	logits := tokens * vocab * fp32
+	return logits, logits
+}
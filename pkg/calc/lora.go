@@ -0,0 +1,136 @@
+package calc
+
+import "math"
+
+const (
+	FineTuneFull  = "full"
+	FineTuneLoRA  = "lora"
+	FineTuneQLoRA = "qlora"
+)
+
+var DefaultLoRATargets = []string{"q_proj", "v_proj"}
+
+var DefaultMLALoRATargets = []string{"q_a_proj", "q_b_proj", "kv_a_proj_with_mqa", "kv_b_proj"}
+
+var LoRATargetModules = map[string]bool{
+	"q_proj":    true,
+	"k_proj":    true,
+	"v_proj":    true,
+	"o_proj":    true,
+	"gate_proj": true,
+	"up_proj":   true,
+	"down_proj": true,
+	// Multi-head latent attention projections.
+	"q_a_proj":           true,
+	"q_b_proj":           true,
+	"kv_a_proj_with_mqa": true,
+	"kv_b_proj":          true,
+}
+
+type TrainingOptions struct {
+	Optimizer        string
+	TrainablePercent float64
+	Method           string
+	LoRARank         int
+	LoRATargets      []string
+	BasePrecision    string
+	Activation       ActivationOptions
+	Policy           PrecisionPolicy
+	Sharding         ShardingOptions
+	Offload          OffloadOptions
+	Overhead         FrameworkOverhead
+	KVCache          KVCacheOptions
+}
+
+func (o TrainingOptions) IsLoRA() bool {
+	return o.Method == FineTuneLoRA || o.Method == FineTuneQLoRA
+}
+
+func (o TrainingOptions) TrainableParams(spec ModelSpec) float64 {
+	if o.IsLoRA() {
+		return GetLoRAParams(spec, o.LoRARank, o.LoRATargets)
+	}
+	return (spec.TotalParams() + GetComponentParams(spec, true)) * o.TrainablePercent / 100
+}
+
+// LoRATargetsFor keeps a full q_proj for MLA models without q_lora_rank.
+func LoRATargetsFor(spec ModelSpec) []string {
+	if !spec.IsMLA() {
+		return DefaultLoRATargets
+	}
+	if spec.QLoRARank == 0 {
+		return []string{"q_proj", "kv_a_proj_with_mqa", "kv_b_proj"}
+	}
+	return DefaultMLALoRATargets
+}
+
+func HasLoRAModule(spec ModelSpec, module string) bool {
+	in, out := loraModuleDims(spec, module)
+	return in > 0 && out > 0
+}
+
+func loraModuleDims(spec ModelSpec, module string) (float64, float64) {
+	hidden := float64(spec.HiddenSize)
+	inter := float64(spec.IntermediateSize)
+	qDim := float64(spec.NumHeads * spec.HeadDim())
+	kvDim := float64(spec.NumKVHeads * spec.HeadDim())
+	if spec.IsMLA() {
+		kvRank := float64(spec.KVLoRARank)
+		switch module {
+		case "q_proj":
+			if spec.QLoRARank > 0 {
+				return 0, 0
+			}
+			return hidden, qDim
+		case "q_a_proj":
+			return hidden, float64(spec.QLoRARank)
+		case "q_b_proj":
+			return float64(spec.QLoRARank), qDim
+		case "kv_a_proj_with_mqa":
+			return hidden, kvRank + float64(spec.QKRopeHeadDim)
+		case "kv_b_proj":
+			return kvRank, float64(spec.NumHeads * (spec.QKNopeHeadDim + spec.ValueHeadDim()))
+		case "o_proj":
+			return float64(spec.NumHeads * spec.ValueHeadDim()), hidden
+		case "k_proj", "v_proj":
+			return 0, 0
+		}
+	}
+	switch module {
+	case "q_proj":
+		return hidden, qDim
+	case "k_proj", "v_proj":
+		return hidden, kvDim
+	case "o_proj":
+		return qDim, hidden
+	case "gate_proj", "up_proj":
+		return hidden, inter
+	case "down_proj":
+		return inter, hidden
+	}
+	return 0, 0
+}
+
+// GetLoRAParams counts one adapter per expert for MoE MLPs.
+func GetLoRAParams(spec ModelSpec, rank int, targetModules []string) float64 {
+	params := 0.0
+	for _, module := range targetModules {
+		in, out := loraModuleDims(spec, module)
+		copies, _ := spec.projectionCopies(module)
+		params += float64(rank) * (in + out) * copies
+	}
+	return params
+}
+
+func GetTrainingWeights(spec ModelSpec, precision string, opts TrainingOptions) (float64, float64) {
+	if !opts.IsLoRA() {
+		return GetWeightFootprint(spec, precision) + GetComponentWeights(spec), 0
+	}
+	basePrecision := precision
+	if opts.Method == FineTuneQLoRA {
+		basePrecision = opts.BasePrecision
+	}
+	baseWeights := GetWeightFootprint(spec, basePrecision) + GetComponentWeights(spec)
+	adapterWeights := GetModelWeights(GetLoRAParams(spec, opts.LoRARank, opts.LoRATargets)/math.Pow(10, 9), precision)
+	return baseWeights, adapterWeights
+}
@@ -0,0 +1,112 @@
+package calc
+
+import "testing"
+
+var llama3_8B = ModelSpec{
+	ModelSize:        8,
+	VocabSize:        128256,
+	HiddenSize:       4096,
+	IntermediateSize: 14336,
+	NumLayers:        32,
+	NumHeads:         32,
+	NumKVHeads:       8,
+	HiddenAct:        "silu",
+}
+
+func TestGetLoRAParams(t *testing.T) {
+	allLinear := []string{"q_proj", "k_proj", "v_proj", "o_proj", "gate_proj", "up_proj", "down_proj"}
+	tests := []struct {
+		name    string
+		spec    ModelSpec
+		rank    int
+		targets []string
+		want    float64
+	}{
+		// Trainable parameter counts as printed by PEFT.
+		{"Llama-2-7B r8 q,v", llama2_7B, 8, DefaultLoRATargets, 4194304},
+		{"Llama-2-7B r16 all linear", llama2_7B, 16, allLinear, 39976960},
+		{"Llama-3-8B r16 q,v", llama3_8B, 16, DefaultLoRATargets, 6815744},
+		{"Llama-3-8B r16 all linear", llama3_8B, 16, allLinear, 41943040},
+		// One adapter per expert: 8 × (4096 + 14336) × 8 experts × 32 layers.
+		{"Mixtral-8x7B r8 gate_proj", mixtral8x7B, 8, []string{"gate_proj"}, 37748736},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetLoRAParams(tt.spec, tt.rank, tt.targets); got != tt.want {
+				t.Errorf("LoRA params = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrainableParams(t *testing.T) {
+	// Llama-3-8B has 8,030,261,248 parameters.
+	const total = 8030261248
+	tests := []struct {
+		name string
+		opts TrainingOptions
+		want float64
+	}{
+		{"full", TrainingOptions{Method: FineTuneFull, TrainablePercent: 100}, total},
+		{"half", TrainingOptions{Method: FineTuneFull, TrainablePercent: 50}, total / 2},
+		{"frozen", TrainingOptions{Method: FineTuneFull, TrainablePercent: 0}, 0},
+		{"lora ignores percent", TrainingOptions{Method: FineTuneLoRA, TrainablePercent: 100, LoRARank: 16, LoRATargets: DefaultLoRATargets}, 6815744},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.TrainableParams(llama3_8B); got != tt.want {
+				t.Errorf("trainable params = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLoRAParamsMLA(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+		want    float64
+	}{
+		// Rank 8 over 27 layers: q_proj 8 × (2048 + 3072), kv_a_proj_with_mqa
+		// 8 × (2048 + 576), kv_b_proj 8 × (512 + 4096), o_proj 8 × (2048 + 2048).
+		{"latent projections", []string{"q_proj", "kv_a_proj_with_mqa", "kv_b_proj", "o_proj"}, 27 * (40960 + 20992 + 36864 + 32768)},
+		// MLA has no separate key and value projections.
+		{"k_proj and v_proj", []string{"k_proj", "v_proj"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetLoRAParams(deepseekV2Lite, 8, tt.targets); got != tt.want {
+				t.Errorf("LoRA params = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoRATargetsFor(t *testing.T) {
+	deepseekV3 := ModelSpec{HiddenSize: 7168, NumLayers: 61, NumHeads: 128, KVLoRARank: 512, QLoRARank: 1536, QKRopeHeadDim: 64, QKNopeHeadDim: 128, VHeadDim: 128}
+	tests := []struct {
+		name string
+		spec ModelSpec
+		want float64
+	}{
+		{"dense q,v", llama3_8B, 6815744},
+		// q_proj, kv_a_proj_with_mqa and kv_b_proj at rank 16 over 27 layers.
+		{"MLA without query bottleneck", deepseekV2Lite, 27 * 2 * (40960 + 20992 + 36864)},
+		// q_a_proj 16 × (7168 + 1536), q_b_proj 16 × (1536 + 24576),
+		// kv_a_proj_with_mqa 16 × (7168 + 576), kv_b_proj 16 × (512 + 32768).
+		{"MLA with query bottleneck", deepseekV3, 61 * 16 * (8704 + 26112 + 7744 + 33280)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := LoRATargetsFor(tt.spec)
+			for _, module := range targets {
+				if !HasLoRAModule(tt.spec, module) {
+					t.Errorf("default target %s is not in the model", module)
+				}
+			}
+			if got := GetLoRAParams(tt.spec, 16, targets); got != tt.want {
+				t.Errorf("LoRA params = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
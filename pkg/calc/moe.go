@@ -0,0 +1,151 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"math"
+)
+
+const mlpActivationFactor = 19.0
+
+type ModelSpec struct {
+	ModelSize           float64
+	VocabSize           int
+	HiddenSize          int
+	IntermediateSize    int
+	NumLayers           int
+	NumHeads            int
+	NumKVHeads          int
+	HeadSize            int
+	HiddenAct           string
+	TieWordEmbeddings   bool
+	AttentionBias       bool
+	MLPBias             bool
+	NumExperts          int
+	NumExpertsPerTok    int
+	QuantScheme         string
+	ModulesToNotConvert []string
+	// LayerTypes marks the local layers when a model mixes both kinds.
+	SlidingWindow int
+	LayerTypes    []string
+	// Multi-head latent attention caches a KVLoRARank latent plus a QKRopeHeadDim rotary key.
+	KVLoRARank    int
+	QLoRARank     int
+	QKRopeHeadDim int
+	QKNopeHeadDim int
+	VHeadDim      int
+	// Blocks declares each layer's mixer and feed-forward for hybrid and Mamba models.
+	Blocks []LayerBlocks
+	SSM    SSMConfig
+	// ScalarHead is the one-wide score head of a reward or value model.
+	ScalarHead bool
+	Components []Component
+}
+
+func (m ModelSpec) IsMLA() bool {
+	return m.KVLoRARank > 0
+}
+
+// ValueHeadDim differs from HeadDim only for MLA.
+func (m ModelSpec) ValueHeadDim() int {
+	if m.IsMLA() && m.VHeadDim > 0 {
+		return m.VHeadDim
+	}
+	return m.HeadDim()
+}
+
+// KVWidth is per token and layer on one of tp ranks; each keeps the whole MLA latent.
+func (m ModelSpec) KVWidth(tp int) float64 {
+	if m.IsMLA() {
+		return float64(m.KVLoRARank + m.QKRopeHeadDim)
+	}
+	kvHeads := (m.NumKVHeads + tp - 1) / tp
+	return 2.0 * float64(kvHeads*m.HeadDim())
+}
+
+func (m ModelSpec) HeadDim() int {
+	if m.HeadSize > 0 {
+		return m.HeadSize
+	}
+	if m.IsMLA() {
+		return m.QKNopeHeadDim + m.QKRopeHeadDim
+	}
+	return GetHeadDim(m.HiddenSize, m.NumHeads)
+}
+
+func (m ModelSpec) IsMoE() bool {
+	return m.NumExperts > 1
+}
+
+func (m ModelSpec) mlpParams() float64 {
+	hidden := float64(m.HiddenSize)
+	inter := float64(m.IntermediateSize)
+	matrices := 2.0
+	if isGatedActivation(m.HiddenAct) {
+		matrices = 3.0
+	}
+	params := matrices * hidden * inter
+	if m.MLPBias {
+		params += (matrices-1)*inter + hidden
+	}
+	return params
+}
+
+func GetExpertParams(spec ModelSpec, numExperts int) float64 {
+	return spec.mlpParams() * float64(spec.CountBlocks(BlockMoE)) * float64(numExperts)
+}
+
+func GetActiveParams(spec ModelSpec) float64 {
+	totalParams := spec.TotalParams()
+	if !spec.IsMoE() {
+		return totalParams
+	}
+	idleExperts := spec.NumExperts - spec.NumExpertsPerTok
+	return totalParams - GetExpertParams(spec, idleExperts)
+}
+
+// The embedding lookup does no matmul work.
+func flopParams(spec ModelSpec) float64 {
+	params := GetActiveParams(spec)
+	if spec.CanCountParams() && (!spec.TieWordEmbeddings || spec.ScalarHead) {
+		params -= CountParameters(spec).Embeddings
+	}
+	return params
+}
+
+func GetFlopsPerToken(spec ModelSpec) float64 {
+	return 2.0 * flopParams(spec)
+}
+
+func GetRouterMemory(batchSize, seqLength, hiddenSize, numExperts, numExpertsPerTok int, precision string) float64 {
+	size := config.BytesPerParam("bfloat16") * activationScale(precision)
+	tokensF := float64(batchSize) * float64(seqLength)
+	routerLogits := tokensF * float64(numExperts) * config.BytesPerParam("float32")
+	dispatchBuffers := 2.0 * tokensF * float64(numExpertsPerTok) * float64(hiddenSize) * size
+	return routerLogits + dispatchBuffers
+}
+
+// GetDecodeWeightBytes reads only the experts some token visits.
+func GetDecodeWeightBytes(spec ModelSpec, precision string, batchSize int) float64 {
+	weights := GetWeightFootprint(spec, precision)
+	if !spec.IsMoE() {
+		return weights
+	}
+	totalParams := spec.TotalParams()
+	size := weights / totalParams
+	activeParams := GetActiveParams(spec)
+	idleParams := totalParams - activeParams
+	routedFraction := float64(spec.NumExpertsPerTok) / float64(spec.NumExperts)
+	idleFraction := float64(spec.NumExperts-spec.NumExpertsPerTok) / float64(spec.NumExperts)
+	touched := 1 - math.Pow(1-routedFraction, float64(batchSize))
+	if idleFraction > 0 {
+		touched = (touched - routedFraction) / idleFraction
+	}
+	if touched < 0 {
+		touched = 0
+	}
+	return (activeParams + idleParams*touched) * size
+}
+
+func GetAttentionFlopsPerKV(spec ModelSpec) float64 {
+	return 2.0 * float64(spec.CountBlocks(BlockAttention)) * float64(spec.NumHeads*(spec.HeadDim()+spec.ValueHeadDim()))
+}
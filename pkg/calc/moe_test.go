@@ -0,0 +1,108 @@
+package calc
+
+import "testing"
+
+var mixtral8x7B = ModelSpec{
+	ModelSize:        46.7,
+	VocabSize:        32000,
+	HiddenSize:       4096,
+	IntermediateSize: 14336,
+	NumLayers:        32,
+	NumHeads:         32,
+	NumKVHeads:       8,
+	HiddenAct:        "silu",
+	NumExperts:       8,
+	NumExpertsPerTok: 2,
+}
+
+func TestGetExpertParams(t *testing.T) {
+	gelu := ModelSpec{HiddenSize: 2048, IntermediateSize: 8192, NumLayers: 24, HiddenAct: "gelu", NumExperts: 16, NumExpertsPerTok: 2}
+	geluBias := gelu
+	geluBias.MLPBias = true
+	tests := []struct {
+		name string
+		spec ModelSpec
+		want float64
+	}{
+		// Gate, up and down: 3 × 4096 × 14336 × 32 layers × 8 experts.
+		{"Mixtral-8x7B SwiGLU", mixtral8x7B, 45097156608},
+		// Up and down only: 2 × 2048 × 8192 × 24 layers × 16 experts.
+		{"GELU experts", gelu, 12884901888},
+		// Plus an 8192-wide up bias and a 2048-wide down bias per expert.
+		{"GELU experts with bias", geluBias, 12884901888 + (8192+2048)*24*16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetExpertParams(tt.spec, tt.spec.NumExperts); got != tt.want {
+				t.Errorf("expert params = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetActiveParams(t *testing.T) {
+	// Mistral reports 46.7B total and 12.9B active parameters for Mixtral-8x7B.
+	if got := mixtral8x7B.TotalParams(); got != 46702792704 {
+		t.Errorf("total params = %.0f, want 46702792704", got)
+	}
+	if got := GetActiveParams(mixtral8x7B); got != 12879925248 {
+		t.Errorf("active params = %.0f, want 12879925248", got)
+	}
+	dense := ModelSpec{ModelSize: 7, HiddenSize: 4096, IntermediateSize: 11008, NumLayers: 32}
+	if got := GetActiveParams(dense); got != 7e9 {
+		t.Errorf("dense active params = %.0f, want all 7e9", got)
+	}
+}
+
+func TestGetFlopsPerToken(t *testing.T) {
+	tests := []struct {
+		name string
+		spec ModelSpec
+		want float64
+	}{
+		// 6,738,415,616 parameters less the 131,072,000-entry embedding table.
+		{"Llama-2-7B", llama2_7B, 2 * 6607343616},
+		// Tied embeddings: the shared matrix still runs once as lm_head.
+		{"Llama-3.2-1B", llama32_1B, 2 * 1235814400},
+		{"Mixtral-8x7B", mixtral8x7B, 2 * (12879925248 - 131072000)},
+		// Without a vocab size the declared model_size is all there is.
+		{"declared size only", ModelSpec{ModelSize: 7, HiddenSize: 4096, NumLayers: 32}, 14e9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetFlopsPerToken(tt.spec); got != tt.want {
+				t.Errorf("FLOPs per token = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRouterMemory(t *testing.T) {
+	// 4,096 tokens: 8 fp32 logits each plus two 16-bit copies of the
+	// hidden state for each of the 2 experts it is routed to.
+	want := 4096.0*8*4 + 2*4096*2*4096*2
+	tests := []struct {
+		precision string
+		want      float64
+	}{
+		{"bfloat16", want},
+		{"int4", want},
+		{"float32", 4096.0*8*4 + 2*4096*2*4096*4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			if got := GetRouterMemory(1, 4096, 4096, 8, 2, tt.precision); got != tt.want {
+				t.Errorf("router memory = %s, want %s", FormatMemory(got), FormatMemory(tt.want))
+			}
+		})
+	}
+}
+
+func TestRouterMemoryIsPerLayer(t *testing.T) {
+	opts := TrainingOptions{Optimizer: "AdamW", TrainablePercent: 100}
+	inference, _ := CalculateInferenceMemory(mixtral8x7B, "bfloat16", 1, 4096, ActivationOptions{}, KVCacheOptions{})
+	training, _, _ := CalculateTrainingMemory(mixtral8x7B, "bfloat16", 1, 4096, opts)
+	if inference.Router != training.Router {
+		t.Errorf("router memory is %s for inference but %s for training", FormatMemory(inference.Router), FormatMemory(training.Router))
+	}
+}
@@ -0,0 +1,52 @@
+package calc
+
+const (
+	OffloadCPU  = "cpu"
+	OffloadNVMe = "nvme"
+
+	// DefaultPCIeBandwidthGBs is the effective bandwidth of a PCIe Gen4 x16 link.
+	DefaultPCIeBandwidthGBs = 25.0
+)
+
+var OffloadTargets = map[string]bool{
+	OffloadCPU:  true,
+	OffloadNVMe: true,
+}
+
+// OffloadOptions follows DeepSpeed ZeRO-Offload and ZeRO-Infinity.
+type OffloadOptions struct {
+	Optimizer string
+	Params    string
+}
+
+func (o OffloadOptions) Enabled() bool {
+	return o.Optimizer != "" || o.Params != ""
+}
+
+// Apply takes updatedWeights, the rank's share copied back to the GPU after each step.
+func (o OffloadOptions) Apply(m TrainingFootprint, updatedWeights float64) TrainingFootprint {
+	place := func(bytes float64, target string) {
+		if target == OffloadNVMe {
+			m.NVMe += bytes
+			m.NVMeTraffic += 2 * bytes
+		} else {
+			m.Host += bytes
+		}
+	}
+	if o.Optimizer != "" {
+		place(m.OptimizerStates+m.MasterWeights, o.Optimizer)
+		m.Host += m.Gradients
+		m.PCIeTraffic += m.Gradients
+		if o.Params == "" {
+			m.PCIeTraffic += updatedWeights
+		}
+		m.OptimizerStates, m.MasterWeights, m.Gradients = 0, 0, 0
+	}
+	if o.Params != "" {
+		place(m.Weights, o.Params)
+		m.PCIeTraffic += 2 * m.Weights
+		m.Weights = 0
+	}
+	m.PerGPU = m.Weights + m.Gradients + m.OptimizerStates + m.MasterWeights + m.CommBuffers + m.Activations + m.KVCache
+	return m
+}
@@ -0,0 +1,120 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"sort"
+)
+
+// OptimizerState with an empty Dtype follows the precision policy; Factored keeps a row and a column per matrix.
+type OptimizerState struct {
+	Name     string
+	Dtype    string
+	Factored bool
+}
+
+// Optimizer states that are Paged sit in unified memory the driver can evict to host RAM.
+type Optimizer struct {
+	States []OptimizerState
+	Paged  bool
+}
+
+// bitsandbytes keeps one fp32 absmax per 2048 8-bit values.
+var optimizerStateFormats = map[string]config.QuantScheme{
+	"int8": {Bits: 8, GroupSize: 2048, ScaleBits: 32},
+}
+
+var (
+	adamStates     = []OptimizerState{{Name: "exp_avg"}, {Name: "exp_avg_sq"}}
+	adam8bitStates = []OptimizerState{{Name: "exp_avg", Dtype: "int8"}, {Name: "exp_avg_sq", Dtype: "int8"}}
+)
+
+// Optimizers costs Shampoo as blocked Distributed Shampoo.
+var Optimizers = map[string]Optimizer{
+	"SGD":            {States: []OptimizerState{{Name: "momentum_buffer"}}},
+	"SGDNoMomentum":  {},
+	"Adam":           {States: adamStates},
+	"AdamW":          {States: adamStates},
+	"QAdamW":         {States: adam8bitStates},
+	"Adam8bit":       {States: adam8bitStates},
+	"PagedAdamW":     {States: adamStates, Paged: true},
+	"PagedAdamW8bit": {States: adam8bitStates, Paged: true},
+	"Adafactor":      {States: []OptimizerState{{Name: "exp_avg_sq", Factored: true}}},
+	"Lion":           {States: []OptimizerState{{Name: "exp_avg"}}},
+	"LAMB":           {States: adamStates},
+	"Sophia":         {States: []OptimizerState{{Name: "exp_avg"}, {Name: "hessian"}}},
+	"Muon":           {States: []OptimizerState{{Name: "momentum_buffer"}}},
+	"Shampoo": {States: []OptimizerState{
+		{Name: "left_statistics"}, {Name: "right_statistics"},
+		{Name: "left_inverse_root"}, {Name: "right_inverse_root"},
+		{Name: "momentum_buffer"}, {Name: "grafting_sum_sq"},
+	}},
+}
+
+func OptimizerNames() []string {
+	names := make([]string, 0, len(Optimizers))
+	for name := range Optimizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FactoredStateFraction counts m + n values per m × n matrix.
+func (o TrainingOptions) FactoredStateFraction(spec ModelSpec) float64 {
+	var full, factored float64
+	add := func(rows, cols, copies float64) {
+		full += rows * cols * copies
+		factored += (rows + cols) * copies
+	}
+	if o.IsLoRA() {
+		rank := float64(o.LoRARank)
+		for _, module := range o.LoRATargets {
+			in, out := loraModuleDims(spec, module)
+			copies, _ := spec.projectionCopies(module)
+			add(in, rank, copies)
+			add(rank, out, copies)
+		}
+	} else {
+		modules := append([]string{}, attentionModules...)
+		if spec.IsMLA() {
+			modules = append([]string{}, mlaAttentionModules...)
+		}
+		modules = append(modules, "up_proj", "down_proj")
+		if isGatedActivation(spec.HiddenAct) {
+			modules = append(modules, "gate_proj")
+		}
+		for _, module := range modules {
+			in, out := loraModuleDims(spec, module)
+			copies, _ := spec.projectionCopies(module)
+			add(in, out, copies)
+		}
+		embeddings := 1.0
+		if !spec.TieWordEmbeddings {
+			embeddings = 2
+		}
+		add(float64(spec.VocabSize), float64(spec.HiddenSize), embeddings)
+	}
+	if full == 0 {
+		return 1
+	}
+	return factored / full
+}
+
+func (p PrecisionPolicy) OptimizerStateBytes(optimizer string, factoredFraction float64) float64 {
+	bytes := 0.0
+	for _, state := range Optimizers[optimizer].States {
+		dtype := state.Dtype
+		if dtype == "" {
+			dtype = p.Optimizer
+		}
+		size := config.BytesPerParam(dtype)
+		if format, ok := optimizerStateFormats[dtype]; ok {
+			size = format.BytesPerParam()
+		}
+		if state.Factored {
+			size *= factoredFraction
+		}
+		bytes += size
+	}
+	return bytes
+}
@@ -0,0 +1,62 @@
+package calc
+
+import "testing"
+
+func TestOptimizerStateBytes(t *testing.T) {
+	policy := PrecisionPolicy{}.WithDefaults("bfloat16")
+	// Two int8 moments with one fp32 absmax per 2048-value block.
+	eightBit := 2 * (1 + 4.0/2048)
+	tests := []struct {
+		optimizer string
+		want      float64
+	}{
+		{"SGD", 4},
+		{"SGDNoMomentum", 0},
+		{"Adam", 8},
+		{"AdamW", 8},
+		{"LAMB", 8},
+		{"Lion", 4},
+		{"Muon", 4},
+		{"Sophia", 8},
+		{"QAdamW", eightBit},
+		{"Adam8bit", eightBit},
+		// Paging moves the states, it does not shrink them.
+		{"PagedAdamW", 8},
+		{"PagedAdamW8bit", eightBit},
+		// A factored state at a fraction of 1 costs one fp32 value per parameter.
+		{"Adafactor", 4},
+		// Four statistics and inverse roots, momentum and grafting, all fp32.
+		{"Shampoo", 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.optimizer, func(t *testing.T) {
+			if got := policy.OptimizerStateBytes(tt.optimizer, 1); got != tt.want {
+				t.Errorf("%s state = %v bytes per parameter, want %v", tt.optimizer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFactoredStateFraction(t *testing.T) {
+	// Per layer: four 4096 × 4096 attention and three 4096 × 11008 MLP matrices,
+	// 202,375,168 parameters factored into 4 × 8,192 + 3 × 15,104 = 78,080 values.
+	// The untied embeddings and lm_head add 2 × 32000 × 4096 factored into 2 × 36,096.
+	full := 32*202375168.0 + 2*32000*4096
+	factored := 32*78080.0 + 2*36096
+	tests := []struct {
+		name string
+		opts TrainingOptions
+		want float64
+	}{
+		{"full fine-tune", TrainingOptions{}, factored / full},
+		// A rank-16 adapter on q_proj factors 4096 × 16 and 16 × 4096 in every layer.
+		{"LoRA", TrainingOptions{Method: FineTuneLoRA, LoRARank: 16, LoRATargets: []string{"q_proj"}}, (4112.0 + 4112) / (65536 + 65536)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.FactoredStateFraction(llama2_7B); !approxEqual(got, tt.want, 1e-12) {
+				t.Errorf("factored fraction = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package calc
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	FrameworkPyTorch     = "pytorch"
+	FrameworkVLLM        = "vllm"
+	FrameworkTensorRTLLM = "tensorrt-llm"
+)
+
+type FrameworkOverhead struct {
+	Fixed         float64
+	Fragmentation float64
+}
+
+var FrameworkOverheads = map[string]FrameworkOverhead{
+	FrameworkPyTorch:     {Fixed: 1.5 * math.Pow(1024, 3), Fragmentation: 0.05},
+	FrameworkVLLM:        {Fixed: 2 * math.Pow(1024, 3), Fragmentation: 0.02},
+	FrameworkTensorRTLLM: {Fixed: 1 * math.Pow(1024, 3)},
+}
+
+func FrameworkNames() []string {
+	names := make([]string, 0, len(FrameworkOverheads))
+	for name := range FrameworkOverheads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f FrameworkOverhead) PerGPU(allocated float64) float64 {
+	return f.Fixed + allocated*f.Fragmentation
+}
+
+func (m TrainingFootprint) WithOverhead(f FrameworkOverhead) TrainingFootprint {
+	m.Framework = f.PerGPU(m.PerGPU)
+	m.PerGPU += m.Framework
+	return m
+}
@@ -0,0 +1,232 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"math"
+)
+
+// tpReplicatedActivationFactor is the activation share only sequence parallelism splits.
+const tpReplicatedActivationFactor = 10.0
+
+type ParallelLayout struct {
+	TensorParallel   int  `json:"tensor_parallel"`
+	PipelineParallel int  `json:"pipeline_parallel"`
+	DataParallel     int  `json:"data_parallel"`
+	ContextParallel  int  `json:"context_parallel"`
+	SequenceParallel bool `json:"sequence_parallel"`
+	MicroBatches     int  `json:"micro_batches"`
+}
+
+func (l ParallelLayout) WithDefaults() ParallelLayout {
+	if l.TensorParallel == 0 {
+		l.TensorParallel = 1
+	}
+	if l.PipelineParallel == 0 {
+		l.PipelineParallel = 1
+	}
+	if l.DataParallel == 0 {
+		l.DataParallel = 1
+	}
+	if l.ContextParallel == 0 {
+		l.ContextParallel = 1
+	}
+	if l.MicroBatches == 0 {
+		l.MicroBatches = l.PipelineParallel
+	}
+	return l
+}
+
+func (l ParallelLayout) GPUs() int {
+	return l.TensorParallel * l.PipelineParallel * l.DataParallel * l.ContextParallel
+}
+
+func (l ParallelLayout) LayersPerStage(numLayers int) int {
+	return int(math.Ceil(float64(numLayers) / float64(l.PipelineParallel)))
+}
+
+// InFlightMicroBatches is how many micro-batches the first 1F1B stage holds.
+func (l ParallelLayout) InFlightMicroBatches() int {
+	if l.MicroBatches < l.PipelineParallel {
+		return l.MicroBatches
+	}
+	return l.PipelineParallel
+}
+
+// stageParams returns the parameters one rank of the first and last pipeline stage holds.
+func stageParams(spec ModelSpec, l ParallelLayout) (float64, float64) {
+	tp := float64(l.TensorParallel)
+	hidden := float64(spec.HiddenSize)
+	var embeddings, lmHead, layerParams float64
+	if spec.CanCountParams() {
+		b := CountParameters(spec)
+		embeddings = b.Embeddings
+		lmHead = b.LMHead
+		layerParams = (b.Attention + b.SSM + b.MLP) / float64(spec.NumLayers)
+	} else {
+		layerParams = spec.TotalParams() / float64(spec.NumLayers)
+	}
+	layers := float64(l.LayersPerStage(spec.NumLayers))
+	stageLayers := layers * (layerParams/tp + 2*hidden)
+	tied := spec.TieWordEmbeddings && !spec.ScalarHead
+	if tied {
+		lmHead = embeddings
+	}
+	if l.PipelineParallel == 1 {
+		if tied {
+			lmHead = 0
+		}
+		total := stageLayers + (embeddings+lmHead)/tp + hidden
+		return total, total
+	}
+	first := stageLayers + embeddings/tp
+	last := stageLayers + lmHead/tp + hidden
+	return first, last
+}
+
+// Hybrid models are costed with their average layer.
+func parallelLayerActivations(spec ModelSpec, precision string, batchSize, seqLength int, l ParallelLayout, opts ActivationOptions) (float64, float64) {
+	scale := activationScale(precision)
+	tp := float64(l.TensorParallel)
+	seqF := float64(seqLength) / float64(l.ContextParallel)
+	fullSeqF := float64(seqLength)
+	batchF := float64(batchSize)
+	headsF := float64(spec.NumHeads)
+	sbh := batchF * seqF * float64(spec.HiddenSize)
+
+	layersF := float64(spec.NumLayers)
+	attentionShare := float64(spec.CountBlocks(BlockAttention)) / layersF
+	denseShare := float64(spec.CountBlocks(BlockMLP)) / layersF
+	moeShare := float64(spec.CountBlocks(BlockMoE)) / layersF
+	normShare := float64(spec.NormCount()-1) / (2 * layersF)
+
+	experts := 1.0
+	router := 0.0
+	if moeShare > 0 {
+		experts = float64(spec.NumExpertsPerTok)
+		router = GetRouterMemory(batchSize, int(seqF), spec.HiddenSize, spec.NumExperts, spec.NumExpertsPerTok, precision) * moeShare
+	}
+	linear := attentionActivationFactor*attentionShare + mlpActivationFactor*(denseShare+experts*moeShare) + normActivationFactor*normShare
+	replicated := math.Min(tpReplicatedActivationFactor, linear)
+	var perLayer float64
+	input := inputActivationFactor * sbh * scale
+	if l.SequenceParallel || l.TensorParallel == 1 {
+		perLayer = linear / tp * sbh * scale
+		input /= tp
+	} else {
+		perLayer = (replicated + (linear-replicated)/tp) * sbh * scale
+	}
+	switch {
+	case opts.FlashAttention:
+		perLayer += headsF / tp * seqF * batchF * config.BytesPerParam("float32") * attentionShare
+	case opts.Checkpointing != CheckpointSelective:
+		perLayer += scoresActivationFactor * headsF / tp * seqF * fullSeqF * batchF * scale * attentionShare
+	}
+	if spec.IsHybrid() {
+		ssmShare := float64(spec.CountBlocks(BlockMamba)) / layersF
+		perLayer += mambaActivations(spec, sbh, batchF*seqF, scale) / tp * ssmShare
+	}
+	return perLayer + router, input
+}
+
+// GetParallelFootprint sizes the busiest rank.
+func GetParallelFootprint(spec ModelSpec, precision string, batchSize, seqLength int, l ParallelLayout, opts TrainingOptions, training bool) TrainingFootprint {
+	l = l.WithDefaults()
+	firstParams, lastParams := stageParams(spec, l)
+	totalParams := spec.TotalParams()
+	policy := opts.Policy.WithDefaults(precision)
+	layers := float64(l.LayersPerStage(spec.NumLayers))
+	perLayer, input := parallelLayerActivations(spec, precision, batchSize, seqLength, l, opts.Activation)
+	logits, loss := GetLogitsMemory(spec, batchSize, int(math.Ceil(float64(seqLength)/float64(l.ContextParallel))), training, opts.Activation.FusedCrossEntropy)
+	vocabActivations := (logits + loss) / float64(l.TensorParallel)
+	// Stages hold the average layer mix; Mamba channels split across TP ranks.
+	kvFraction := 1.0
+	if full := GetKVCacheBreakdown(spec, 1, seqLength, opts.KVCache.WithDefaults(precision).Dtype); full.GlobalPerLayer > 0 {
+		kvFraction = full.Total / (float64(spec.NumLayers) * full.GlobalPerLayer)
+	}
+	ssmState, convState := GetSSMState(spec, batchSize, precision)
+	stageState := (ssmState + convState) * layers / float64(spec.NumLayers) / float64(l.TensorParallel)
+
+	weightBytes := GetWeightFootprint(spec, precision) / totalParams
+	trainableFraction := 0.0
+	if training {
+		baseWeights, adapterWeights := GetTrainingWeights(spec, policy.Weights, opts)
+		weightBytes = (baseWeights + adapterWeights) / totalParams
+		trainableFraction = opts.TrainableParams(spec) / totalParams
+	}
+
+	stage := func(params float64, inFlight int, head float64) TrainingFootprint {
+		var f TrainingFootprint
+		f.Weights = params * weightBytes
+		if !training {
+			seqPerRank := int(math.Ceil(float64(seqLength) / float64(l.ContextParallel)))
+			kvCache := GetLayerKVCache(spec, batchSize, seqPerRank, int(layers), l.TensorParallel, opts.KVCache.WithDefaults(precision).Dtype) * kvFraction
+			f.KVCache, _ = opts.KVCache.Split(kvCache)
+			f.KVCache += stageState
+			f.Activations = perLayer + head
+			f.PerGPU = f.Weights + f.KVCache + f.Activations
+			return f
+		}
+		trainable := params * trainableFraction
+		f.Gradients = trainable * config.BytesPerParam(policy.Gradients)
+		f.OptimizerStates = trainable * policy.OptimizerStateBytes(opts.Optimizer, opts.FactoredStateFraction(spec))
+		f.MasterWeights = trainable * policy.MasterBytes()
+		if opts.Activation.Checkpointing == CheckpointFull {
+			f.Activations = layers*input*float64(inFlight) + perLayer
+		} else {
+			f.Activations = layers * perLayer * float64(inFlight)
+		}
+		f.Activations += head
+		sharding := opts.Sharding
+		sharding.DataParallel = l.DataParallel
+		sharding.TensorParallel = l.TensorParallel
+		if l.DataParallel == 1 {
+			sharding.Strategy = ""
+		} else if sharding.Strategy == "" {
+			sharding.Strategy = ShardDDP
+		}
+		if !sharding.Enabled() {
+			f.PerGPU = f.Weights + f.Gradients + f.OptimizerStates + f.MasterWeights + f.Activations
+			return f
+		}
+		return sharding.Shard(spec, f, trainable, policy)
+	}
+
+	// The vocab-parallel logits and loss live on the last stage only.
+	firstHead := 0.0
+	if l.PipelineParallel == 1 {
+		firstHead = vocabActivations
+	}
+	first := stage(firstParams, l.InFlightMicroBatches(), firstHead)
+	last := stage(lastParams, 1, vocabActivations)
+	if last.PerGPU > first.PerGPU {
+		return last.WithOverhead(opts.Overhead)
+	}
+	return first.WithOverhead(opts.Overhead)
+}
+
+func EnumerateLayouts(spec ModelSpec, numGPUs int, base ParallelLayout) []ParallelLayout {
+	microBatches := base.MicroBatches
+	base = base.WithDefaults()
+	var layouts []ParallelLayout
+	if numGPUs%base.ContextParallel != 0 {
+		return layouts
+	}
+	remaining := numGPUs / base.ContextParallel
+	for tp := 1; tp <= remaining; tp++ {
+		if remaining%tp != 0 || spec.NumHeads%tp != 0 {
+			continue
+		}
+		for pp := 1; pp <= remaining/tp; pp++ {
+			if (remaining/tp)%pp != 0 || pp > spec.NumLayers {
+				continue
+			}
+			l := base
+			l.TensorParallel = tp
+			l.PipelineParallel = pp
+			l.DataParallel = remaining / (tp * pp)
+			l.MicroBatches = microBatches
+			layouts = append(layouts, l.WithDefaults())
+		}
+	}
+	return layouts
+}
@@ -0,0 +1,73 @@
+package calc
+
+import "testing"
+
+func TestEnumerateLayouts(t *testing.T) {
+	narrow := llama2_7B
+	narrow.NumHeads = 12
+	tests := []struct {
+		name    string
+		spec    ModelSpec
+		numGPUs int
+		base    ParallelLayout
+		want    int
+	}{
+		// TP ∈ {1, 2, 4, 8} with PP dividing the rest: 4 + 3 + 2 + 1.
+		{"8 GPUs", llama2_7B, 8, ParallelLayout{}, 10},
+		// TP=8 does not divide 12 heads.
+		{"8 GPUs, 12 heads", narrow, 8, ParallelLayout{}, 9},
+		// CP=2 leaves 4 GPUs: 3 + 2 + 1.
+		{"8 GPUs, CP=2", llama2_7B, 8, ParallelLayout{ContextParallel: 2}, 6},
+		{"CP does not divide the GPUs", llama2_7B, 7, ParallelLayout{ContextParallel: 2}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layouts := EnumerateLayouts(tt.spec, tt.numGPUs, tt.base)
+			if len(layouts) != tt.want {
+				t.Fatalf("got %d layouts, want %d", len(layouts), tt.want)
+			}
+			for _, l := range layouts {
+				if l.GPUs() != tt.numGPUs {
+					t.Errorf("layout %+v uses %d GPUs, want %d", l, l.GPUs(), tt.numGPUs)
+				}
+			}
+		})
+	}
+}
+
+func TestStageParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		layout      ParallelLayout
+		first, last float64
+	}{
+		// A single stage holds the whole of Llama-2-7B.
+		{"no parallelism", ParallelLayout{}, 6738415616, 6738415616},
+		// 8 layers of 202,375,168 weights plus two norms each; the embedding
+		// sits on the first stage, lm_head and the final norm on the last.
+		{"PP=4", ParallelLayout{PipelineParallel: 4}, 1750138880, 1750142976},
+		// TP splits layer weights and the vocab-parallel embedding and lm_head.
+		{"TP=2", ParallelLayout{TensorParallel: 2}, 3369340928, 3369340928},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last := stageParams(llama2_7B, tt.layout.WithDefaults())
+			if first != tt.first || last != tt.last {
+				t.Errorf("stage params = (%.0f, %.0f), want (%.0f, %.0f)", first, last, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestGetParallelFootprintInference(t *testing.T) {
+	// Llama-3-70B at TP=8: 8,820,367,360 bf16 weights per rank and one of
+	// the eight KV heads over 8,192 tokens and 80 layers.
+	layout := ParallelLayout{TensorParallel: 8}
+	f := GetParallelFootprint(llama3_70B, "bfloat16", 1, 8192, layout, TrainingOptions{}, false)
+	if want := 8820367360.0 * 2; f.Weights != want {
+		t.Errorf("weights = %s, want %s", FormatMemory(f.Weights), FormatMemory(want))
+	}
+	if want := 2.0 * 8192 * 80 * 128 * 2; f.KVCache != want {
+		t.Errorf("KV cache = %s, want %s", FormatMemory(f.KVCache), FormatMemory(want))
+	}
+}
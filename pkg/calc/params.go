@@ -0,0 +1,110 @@
+package calc
+
+import (
+	"math"
+	"strings"
+)
+
+const ParamMismatchThreshold = 5.0
+
+type ParamBreakdown struct {
+	Embeddings float64 `json:"embeddings"`
+	Attention  float64 `json:"attention"`
+	MLP        float64 `json:"mlp"`
+	SSM        float64 `json:"ssm,omitempty"`
+	Norms      float64 `json:"norms"`
+	LMHead     float64 `json:"lm_head"`
+	Total      float64 `json:"total"`
+}
+
+func (m ModelSpec) CanCountParams() bool {
+	hasFeedForward := m.CountBlocks(BlockMLP)+m.CountBlocks(BlockMoE) > 0
+	return m.VocabSize > 0 && (m.IntermediateSize > 0 || !hasFeedForward)
+}
+
+// TotalParams falls back to model_size for incomplete configs, but never below the experts.
+func (m ModelSpec) TotalParams() float64 {
+	if m.CanCountParams() {
+		return CountParameters(m).Total
+	}
+	return math.Max(m.ModelSize*math.Pow(10, 9), GetExpertParams(m, m.NumExperts))
+}
+
+func isGatedActivation(hiddenAct string) bool {
+	switch strings.ToLower(hiddenAct) {
+	case "", "silu", "swish", "swiglu", "geglu":
+		return true
+	}
+	return false
+}
+
+func mlaAttentionParams(spec ModelSpec) float64 {
+	hidden := float64(spec.HiddenSize)
+	heads := float64(spec.NumHeads)
+	qkDim := float64(spec.HeadDim())
+	vDim := float64(spec.ValueHeadDim())
+	kvRank := float64(spec.KVLoRARank)
+	rope := float64(spec.QKRopeHeadDim)
+	nope := float64(spec.QKNopeHeadDim)
+
+	query := hidden * heads * qkDim
+	if spec.QLoRARank > 0 {
+		qRank := float64(spec.QLoRARank)
+		query = hidden*qRank + qRank + qRank*heads*qkDim
+	}
+	kv := hidden*(kvRank+rope) + kvRank + kvRank*heads*(nope+vDim)
+	return query + kv + heads*vDim*hidden
+}
+
+func CountParameters(spec ModelSpec) ParamBreakdown {
+	hidden := float64(spec.HiddenSize)
+	vocab := float64(spec.VocabSize)
+	qDim := float64(spec.NumHeads * spec.HeadDim())
+	kvDim := float64(spec.NumKVHeads * spec.HeadDim())
+
+	attention := hidden*qDim + 2*hidden*kvDim + qDim*hidden
+	if spec.AttentionBias {
+		attention += qDim + 2*kvDim + hidden
+	}
+	if spec.IsMLA() {
+		attention = mlaAttentionParams(spec)
+	}
+
+	expert := spec.mlpParams()
+	moe := expert*float64(spec.NumExperts) + hidden*float64(spec.NumExperts)
+
+	var b ParamBreakdown
+	b.Embeddings = vocab * hidden
+	switch {
+	case spec.ScalarHead:
+		b.LMHead = hidden
+	case !spec.TieWordEmbeddings:
+		b.LMHead = vocab * hidden
+	}
+	for _, layer := range spec.Layers() {
+		switch layer.Mixer {
+		case BlockAttention:
+			b.Attention += attention
+		case BlockMamba:
+			b.SSM += GetSSMParams(spec)
+		}
+		switch layer.FeedForward {
+		case BlockMLP:
+			b.MLP += expert
+		case BlockMoE:
+			b.MLP += moe
+		}
+	}
+	b.Attention += GetCrossAttentionParams(spec)
+	b.Norms = float64(spec.NormCount()) * hidden
+	b.Total = b.Embeddings + b.Attention + b.SSM + b.MLP + b.Norms + b.LMHead
+	return b
+}
+
+func GetParamGap(spec ModelSpec) float64 {
+	declared := spec.ModelSize * math.Pow(10, 9)
+	if declared <= 0 || !spec.CanCountParams() {
+		return 0
+	}
+	return (CountParameters(spec).Total + GetComponentParams(spec, false) - declared) / declared * 100
+}
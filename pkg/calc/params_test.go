@@ -0,0 +1,153 @@
+package calc
+
+import "testing"
+
+var llama2_7B = ModelSpec{
+	ModelSize:        7,
+	VocabSize:        32000,
+	HiddenSize:       4096,
+	IntermediateSize: 11008,
+	NumLayers:        32,
+	NumHeads:         32,
+	NumKVHeads:       32,
+	HiddenAct:        "silu",
+}
+
+var llama32_1B = ModelSpec{
+	ModelSize:         1.24,
+	VocabSize:         128256,
+	HiddenSize:        2048,
+	IntermediateSize:  8192,
+	NumLayers:         16,
+	NumHeads:          32,
+	NumKVHeads:        8,
+	HeadSize:          64,
+	HiddenAct:         "silu",
+	TieWordEmbeddings: true,
+}
+
+func TestCountParameters(t *testing.T) {
+	gpt2 := ModelSpec{
+		VocabSize:        50257,
+		HiddenSize:       768,
+		IntermediateSize: 3072,
+		NumLayers:        12,
+		NumHeads:         12,
+		NumKVHeads:       12,
+		HiddenAct:        "gelu_new",
+		AttentionBias:    true,
+		MLPBias:          true,
+	}
+	llama2Reward := llama2_7B
+	llama2Reward.ScalarHead = true
+	llama32Reward := llama32_1B
+	llama32Reward.ScalarHead = true
+	tests := []struct {
+		name string
+		spec ModelSpec
+		want ParamBreakdown
+	}{
+		// 4 × 4096² attention and 3 × 4096 × 11008 MLP per layer, untied lm_head.
+		{"Llama-2-7B", llama2_7B, ParamBreakdown{
+			Embeddings: 131072000,
+			Attention:  2147483648,
+			MLP:        4328521728,
+			Norms:      266240,
+			LMHead:     131072000,
+			Total:      6738415616,
+		}},
+		// GQA with 8 × 64-wide KV heads and tied embeddings.
+		{"Llama-3.2-1B", llama32_1B, ParamBreakdown{
+			Embeddings: 262668288,
+			Attention:  167772160,
+			MLP:        805306368,
+			Norms:      67584,
+			Total:      1235814400,
+		}},
+		// Two biased MLP matrices and biased attention; norms are counted without bias.
+		{"GPT-2 style GELU", gpt2, ParamBreakdown{
+			Embeddings: 38597376,
+			Attention:  28348416,
+			MLP:        56669184,
+			Norms:      19200,
+			LMHead:     38597376,
+			Total:      162231552,
+		}},
+		// A reward head is one 4096-wide row in place of the 32000 × 4096 lm_head.
+		{"Llama-2-7B reward model", llama2Reward, ParamBreakdown{
+			Embeddings: 131072000,
+			Attention:  2147483648,
+			MLP:        4328521728,
+			Norms:      266240,
+			LMHead:     4096,
+			Total:      6607347712,
+		}},
+		// Tied embeddings stay as the input table; the score head is separate.
+		{"Llama-3.2-1B reward model", llama32Reward, ParamBreakdown{
+			Embeddings: 262668288,
+			Attention:  167772160,
+			MLP:        805306368,
+			Norms:      67584,
+			LMHead:     2048,
+			Total:      1235816448,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountParameters(tt.spec); got != tt.want {
+				t.Errorf("CountParameters = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetParamGap(t *testing.T) {
+	undeclared := llama2_7B
+	undeclared.ModelSize = 0
+	incomplete := llama2_7B
+	incomplete.VocabSize = 0
+	tests := []struct {
+		name string
+		spec ModelSpec
+		want float64
+	}{
+		// 6.738B derived against 7B declared.
+		{"Llama-2-7B", llama2_7B, -3.7369},
+		// 1.2358B derived against 1.24B declared.
+		{"Llama-3.2-1B", llama32_1B, -0.3375},
+		{"no declared size", undeclared, 0},
+		{"incomplete config", incomplete, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetParamGap(tt.spec); !approxEqual(got, tt.want, 0.001) {
+				t.Errorf("param gap = %.4f%%, want %.4f%%", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMLAAttentionParams(t *testing.T) {
+	// DeepSeek-V3 adds a 1,536-wide query bottleneck with its own norm.
+	deepseekV3 := ModelSpec{HiddenSize: 7168, NumHeads: 128, KVLoRARank: 512, QLoRARank: 1536, QKRopeHeadDim: 64, QKNopeHeadDim: 128, VHeadDim: 128}
+	tests := []struct {
+		name string
+		spec ModelSpec
+		want float64
+	}{
+		// q_proj 2048 × 3072, kv_a_proj_with_mqa 2048 × 576, the 512 latent
+		// norm, kv_b_proj 512 × 4096 and o_proj 2048 × 2048.
+		{"DeepSeek-V2-Lite", deepseekV2Lite, 6291456 + 1179648 + 512 + 2097152 + 4194304},
+		// q_a_proj 7168 × 1536 with its norm, q_b_proj 1536 × 24576,
+		// kv_a_proj_with_mqa 7168 × 576 with its norm, kv_b_proj 512 × 32768
+		// and o_proj 16384 × 7168.
+		{"DeepSeek-V3", deepseekV3, 11010048 + 1536 + 37748736 + 4128768 + 512 + 16777216 + 117440512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mlaAttentionParams(tt.spec); got != tt.want {
+				t.Errorf("attention params = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package calc
+
+import "compute-gauge/pkg/config"
+
+const NoMasterWeights = "none"
+
+type PrecisionPolicy struct {
+	Weights   string `json:"weights,omitempty"`
+	Master    string `json:"master_weights,omitempty"`
+	Gradients string `json:"gradients,omitempty"`
+	Optimizer string `json:"optimizer_states,omitempty"`
+}
+
+// WithDefaults gives 16-bit weights an fp32 master copy and keeps gradients and moments in fp32.
+func (p PrecisionPolicy) WithDefaults(precision string) PrecisionPolicy {
+	if p.Weights == "" {
+		p.Weights = precision
+	}
+	if p.Master == "" {
+		switch p.Weights {
+		case "float16", "bfloat16":
+			p.Master = "float32"
+		default:
+			p.Master = NoMasterWeights
+		}
+	}
+	if p.Gradients == "" {
+		p.Gradients = "float32"
+	}
+	if p.Optimizer == "" {
+		p.Optimizer = "float32"
+	}
+	return p
+}
+
+func (p PrecisionPolicy) MasterBytes() float64 {
+	if p.Master == NoMasterWeights {
+		return 0
+	}
+	return config.BytesPerParam(p.Master)
+}
+
+func (p PrecisionPolicy) BytesPerParam(optimizer string, factoredFraction float64) float64 {
+	return config.BytesPerParam(p.Weights) + p.MasterBytes() + config.BytesPerParam(p.Gradients) + p.OptimizerStateBytes(optimizer, factoredFraction)
+}
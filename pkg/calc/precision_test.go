@@ -0,0 +1,53 @@
+package calc
+
+import "testing"
+
+func TestPrecisionPolicyWithDefaults(t *testing.T) {
+	tests := []struct {
+		precision string
+		policy    PrecisionPolicy
+		want      PrecisionPolicy
+	}{
+		{"bfloat16", PrecisionPolicy{}, PrecisionPolicy{"bfloat16", "float32", "float32", "float32"}},
+		{"float16", PrecisionPolicy{}, PrecisionPolicy{"float16", "float32", "float32", "float32"}},
+		{"float32", PrecisionPolicy{}, PrecisionPolicy{"float32", NoMasterWeights, "float32", "float32"}},
+		{"int8", PrecisionPolicy{}, PrecisionPolicy{"int8", NoMasterWeights, "float32", "float32"}},
+		// Explicit choices are kept as given.
+		{"bfloat16", PrecisionPolicy{Master: NoMasterWeights, Gradients: "bfloat16"}, PrecisionPolicy{"bfloat16", NoMasterWeights, "bfloat16", "float32"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			if got := tt.policy.WithDefaults(tt.precision); got != tt.want {
+				t.Errorf("WithDefaults = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrecisionPolicyBytesPerParam(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision string
+		policy    PrecisionPolicy
+		optimizer string
+		want      float64
+	}{
+		// 2 weight + 4 master + 4 gradient + 2 × 4 moments.
+		{"bf16 AdamW", "bfloat16", PrecisionPolicy{}, "AdamW", 18},
+		// fp32 weights are their own master copy.
+		{"fp32 AdamW", "float32", PrecisionPolicy{}, "AdamW", 16},
+		{"bf16 SGD", "bfloat16", PrecisionPolicy{}, "SGD", 14},
+		// Two int8 moments with one fp32 absmax per 2048 values.
+		{"bf16 QAdamW", "bfloat16", PrecisionPolicy{}, "QAdamW", 10 + 2*(1+4.0/2048)},
+		{"bf16 gradients and moments", "bfloat16", PrecisionPolicy{Gradients: "bfloat16", Optimizer: "bfloat16"}, "AdamW", 12},
+		{"pure bf16", "bfloat16", PrecisionPolicy{Master: NoMasterWeights, Gradients: "bfloat16", Optimizer: "bfloat16"}, "AdamW", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy.WithDefaults(tt.precision)
+			if got := policy.BytesPerParam(tt.optimizer, 1); got != tt.want {
+				t.Errorf("bytes per param = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
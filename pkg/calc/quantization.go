@@ -0,0 +1,92 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"strings"
+)
+
+var attentionModules = []string{"q_proj", "k_proj", "v_proj", "o_proj"}
+var mlaAttentionModules = []string{"q_proj", "q_a_proj", "q_b_proj", "kv_a_proj_with_mqa", "kv_b_proj", "o_proj"}
+var mlpModules = []string{"gate_proj", "up_proj", "down_proj"}
+
+// moduleParams counts the parameters behind a module path such as "model.layers.3.self_attn.q_proj" or "mamba".
+func moduleParams(spec ModelSpec, breakdown ParamBreakdown, path string) float64 {
+	parts := strings.Split(path, ".")
+	name := parts[len(parts)-1]
+	switch name {
+	case "lm_head":
+		return breakdown.LMHead
+	case "embed_tokens":
+		return breakdown.Embeddings
+	}
+	if name == "mamba" {
+		if strings.Contains(path, "layers.") {
+			return GetSSMParams(spec)
+		}
+		return GetSSMParams(spec) * float64(spec.CountBlocks(BlockMamba))
+	}
+	var modules []string
+	switch name {
+	case "self_attn":
+		modules = attentionModules
+		if spec.IsMLA() {
+			modules = mlaAttentionModules
+		}
+	case "mlp":
+		modules = mlpModules
+	default:
+		modules = []string{name}
+	}
+	params := 0.0
+	for _, module := range modules {
+		in, out := loraModuleDims(spec, module)
+		copies, layers := spec.projectionCopies(module)
+		if layers == 0 {
+			continue
+		}
+		if strings.Contains(path, "layers.") {
+			copies /= layers
+		}
+		params += in * out * copies
+	}
+	return params
+}
+
+// No scheme quantizes the norms.
+func unquantizedParams(spec ModelSpec, modules []string) float64 {
+	breakdown := CountParameters(spec)
+	params := breakdown.Norms
+	seen := make(map[string]bool)
+	for _, module := range modules {
+		if seen[module] {
+			continue
+		}
+		seen[module] = true
+		params += moduleParams(spec, breakdown, module)
+	}
+	if params > breakdown.Total {
+		return breakdown.Total
+	}
+	return params
+}
+
+func GetWeightFootprint(spec ModelSpec, precision string) float64 {
+	schemeName, keptDtype := precision, config.UnquantizedDtype
+	if spec.QuantScheme != "" {
+		schemeName = spec.QuantScheme
+		if !config.QuantSchemes[precision].IsQuantized() {
+			keptDtype = precision
+		}
+	}
+	scheme, ok := config.QuantSchemes[schemeName]
+	if !ok {
+		return 0
+	}
+	totalParams := spec.TotalParams()
+	weights := totalParams * scheme.BytesPerParam()
+	if !spec.CanCountParams() || (spec.QuantScheme == "" && !scheme.IsQuantized()) {
+		return weights
+	}
+	kept := unquantizedParams(spec, append(append([]string{}, scheme.Unquantized...), spec.ModulesToNotConvert...))
+	return weights + kept*(config.BytesPerParam(keptDtype)-scheme.BytesPerParam())
+}
@@ -0,0 +1,124 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"testing"
+)
+
+func TestGetWeightFootprint(t *testing.T) {
+	// Llama-3-8B has 8,030,261,248 parameters. The untied 128k embeddings
+	// and lm_head (2 × 525,336,576) and the norms (65 × 4096) stay in bf16
+	// under every quantization scheme; the rest is quantized.
+	const kept = 2*525336576 + 65*4096
+	const quantized = 8030261248 - kept
+	tests := []struct {
+		precision string
+		bits      float64
+		want      float64
+	}{
+		{"float32", 32, 8030261248 * 4},
+		{"bfloat16", 16, 8030261248 * 2},
+		// About 5.7 GB, the size of the published 4-bit group-128 checkpoints.
+		{"gptq_int4_g128", 4 + 20.0/128, 0},
+		{"awq_int4_g128", 4 + 20.0/128, 0},
+		{"int4", 4.125, 0},
+		{"int8", 8.125, 0},
+		{"bnb_int8", 8 + 32.0/4096, 0},
+		{"fp8", 8, 0},
+		{"nf4", 4 + (8+32.0/256)/64, 0},
+		{"mxfp4", 4.25, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			want := tt.want
+			if want == 0 {
+				want = quantized*tt.bits/8 + kept*2
+			}
+			got := GetWeightFootprint(llama3_8B, tt.precision)
+			if !approxEqual(got, want, 1e-9) {
+				t.Errorf("weights = %.0f B (%s), want %.0f B", got, FormatMemory(got), want)
+			}
+		})
+	}
+	if got := GetWeightFootprint(llama3_8B, "gptq_int4_g128"); got < 5.7e9 || got > 5.75e9 {
+		t.Errorf("GPTQ weights = %.0f B, want about 5.7 GB", got)
+	}
+}
+
+func TestQuantSchemeBitsPerParam(t *testing.T) {
+	tests := map[string]float64{
+		"bfloat16":       16,
+		"int8":           8.125,
+		"bnb_int8":       8 + 32.0/4096,
+		"int4":           4.125,
+		"gptq_int4_g128": 4 + 20.0/128,
+		"nf4":            4 + (8+32.0/256)/64,
+		"mxfp4":          4.25,
+		"gguf_q4_k_m":    4.5,
+	}
+	for name, want := range tests {
+		if got := config.QuantSchemes[name].BitsPerParam(); got != want {
+			t.Errorf("%s: %v bits per parameter, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPlainDtypes(t *testing.T) {
+	plain := map[string]bool{}
+	for _, name := range config.PlainDtypeNames() {
+		plain[name] = true
+	}
+	for _, name := range []string{"float32", "float16", "bfloat16"} {
+		if !plain[name] {
+			t.Errorf("%s should be a plain dtype", name)
+		}
+	}
+	for name, scheme := range config.QuantSchemes {
+		if scheme.Bits < 16 && plain[name] {
+			t.Errorf("%s is a %v-bit weight format but is listed as a plain dtype", name, scheme.Bits)
+		}
+	}
+}
+
+func TestModuleParams(t *testing.T) {
+	breakdown := CountParameters(llama3_8B)
+	tests := []struct {
+		path string
+		want float64
+	}{
+		{"lm_head", 128256 * 4096},
+		{"model.embed_tokens", 128256 * 4096},
+		// q and o are 4096 × 4096, k and v 4096 × 1024 with 8 KV heads.
+		{"model.layers.0.self_attn", 2*4096*4096 + 2*4096*1024},
+		{"model.layers.3.mlp", 3 * 4096 * 14336},
+		// Without a layer index the module is matched in all 32 layers.
+		{"q_proj", 32 * 4096 * 4096},
+		{"model.layers.0.input_layernorm", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := moduleParams(llama3_8B, breakdown, tt.path); got != tt.want {
+				t.Errorf("moduleParams = %.0f, want %.0f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetWeightFootprintPreQuantized(t *testing.T) {
+	// An FP8 checkpoint that keeps the embeddings, lm_head and layer 0's
+	// MLP in bf16: those and the norms cost 2 bytes, the rest 1 byte. The
+	// repeated lm_head is counted once.
+	spec := llama3_8B
+	spec.QuantScheme = "fp8"
+	spec.ModulesToNotConvert = []string{"embed_tokens", "lm_head", "model.layers.0.mlp", "lm_head"}
+	const kept = 2*525336576 + 65*4096 + 3*4096*14336
+	want := 8030261248.0 + kept
+	if got := GetWeightFootprint(spec, "bfloat16"); got != want {
+		t.Errorf("weights = %.0f B, want %.0f B", got, want)
+	}
+	// Excluding every module leaves the whole model in bf16.
+	spec.ModulesToNotConvert = []string{"self_attn", "mlp", "embed_tokens", "lm_head"}
+	if got, want := GetWeightFootprint(spec, "bfloat16"), 8030261248.0*2; got != want {
+		t.Errorf("fully excluded weights = %.0f B, want %.0f B", got, want)
+	}
+}
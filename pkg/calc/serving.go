@@ -0,0 +1,96 @@
+package calc
+
+import "math"
+
+const (
+	DefaultGPUMemoryUtilization = 0.9
+	DefaultKVBlockSize          = 16
+)
+
+type ServingOptions struct {
+	GPUMemoryUtilization float64
+	BlockSize            int
+	AvgPromptLength      int
+	AvgOutputLength      int
+	MaxModelLen          int
+	TensorParallel       int
+	Activation           ActivationOptions
+	KVCache              KVCacheOptions
+	Overhead             FrameworkOverhead
+}
+
+type ServingCapacity struct {
+	Weights                float64
+	Activations            float64
+	FrameworkOverhead      float64
+	KVBudget               float64
+	KVBytesPerBlock        float64
+	SSMStatePerSequence    float64
+	CrossKVPerSequence     float64
+	NumBlocks              int
+	BlocksPerSequence      int
+	MaxConcurrentSequences int
+	WastedTokensPerSeq     float64
+	WasteFraction          float64
+	WastedKV               float64
+}
+
+func GetServingCapacity(spec ModelSpec, precision string, gpuMemoryBytes float64, opts ServingOptions) ServingCapacity {
+	tp := opts.TensorParallel
+	if tp < 1 {
+		tp = 1
+	}
+	weights, _, activations := calculateBaseMemory(spec, precision, 1, opts.MaxModelLen, false, opts.Activation, opts.KVCache)
+	overhead := opts.Overhead.PerGPU(0)*float64(tp) + (weights+activations.Total)*opts.Overhead.Fragmentation
+	c := ServingCapacity{
+		Weights:           weights,
+		Activations:       activations.Total,
+		FrameworkOverhead: overhead,
+		KVBudget:          gpuMemoryBytes*opts.GPUMemoryUtilization - weights - activations.Total - overhead,
+		KVBytesPerBlock:   GetLayerKVCache(spec, 1, opts.BlockSize, spec.CountBlocks(BlockAttention), tp, opts.KVCache.WithDefaults(precision).Dtype) * float64(tp),
+	}
+	ssmState, convState := GetSSMState(spec, 1, precision)
+	c.SSMStatePerSequence = ssmState + convState
+	c.CrossKVPerSequence = GetCrossAttentionKV(spec, 1, opts.KVCache.WithDefaults(precision).Dtype)
+	fixed := c.SSMStatePerSequence + c.CrossKVPerSequence
+	if c.KVBudget <= 0 || c.KVBytesPerBlock+fixed <= 0 {
+		c.KVBudget = math.Max(c.KVBudget, 0)
+		return c
+	}
+	tokens := opts.AvgPromptLength + opts.AvgOutputLength
+	c.BlocksPerSequence = blocksPerSequence(spec, tokens, opts.BlockSize)
+	perSequence := float64(c.BlocksPerSequence)*c.KVBytesPerBlock + fixed
+	c.MaxConcurrentSequences = int(c.KVBudget / perSequence)
+	if c.KVBytesPerBlock > 0 {
+		c.NumBlocks = int((c.KVBudget - float64(c.MaxConcurrentSequences)*fixed) / c.KVBytesPerBlock)
+		c.WastedTokensPerSeq = float64(opts.BlockSize-1) / 2
+		c.WasteFraction = c.WastedTokensPerSeq / float64(c.BlocksPerSequence*opts.BlockSize)
+		c.WastedKV = c.WastedTokensPerSeq * float64(c.MaxConcurrentSequences) * c.KVBytesPerBlock / float64(opts.BlockSize)
+	}
+	return c
+}
+
+// Sliding-window layers stop at the window and lower the all-layer average.
+func blocksPerSequence(spec ModelSpec, tokens, blockSize int) int {
+	global := (tokens + blockSize - 1) / blockSize
+	local := global
+	if spec.SlidingWindow > 0 && spec.SlidingWindow < tokens {
+		local = (spec.SlidingWindow + blockSize - 1) / blockSize
+	}
+	layers, layerBlocks := 0, 0
+	for i, layer := range spec.Layers() {
+		if layer.Mixer != BlockAttention {
+			continue
+		}
+		layers++
+		if spec.IsSlidingLayer(i) {
+			layerBlocks += local
+		} else {
+			layerBlocks += global
+		}
+	}
+	if layers == 0 {
+		return global
+	}
+	return (layerBlocks + layers - 1) / layers
+}
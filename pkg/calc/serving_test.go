@@ -0,0 +1,96 @@
+package calc
+
+import "testing"
+
+func TestGetServingCapacity(t *testing.T) {
+	opts := ServingOptions{
+		GPUMemoryUtilization: 0.9,
+		BlockSize:            16,
+		AvgPromptLength:      1000,
+		AvgOutputLength:      250,
+		MaxModelLen:          8192,
+		Activation:           ActivationOptions{FlashAttention: true},
+	}
+	c := GetServingCapacity(llama3_8B, "bfloat16", 80*gib, opts)
+	// 90% of 80 GiB less 16,060,522,496 bytes of weights, a 1,141,899,264
+	// byte profiling pass over 8,192 tokens and 128,256 fp32 logits.
+	if want := 0.9*80*gib - 16060522496 - 1141899264 - 128256*4; c.KVBudget != want {
+		t.Errorf("KV budget = %.0f, want %.0f", c.KVBudget, want)
+	}
+	// 16 tokens × 32 layers × 8 KV heads × 128 × 2 (K and V) × 2 bytes.
+	if c.KVBytesPerBlock != 2*1024*1024 {
+		t.Errorf("block = %.0f bytes, want 2 MiB", c.KVBytesPerBlock)
+	}
+	// 1,250 tokens need ⌈1250 / 16⌉ = 79 blocks.
+	if c.NumBlocks != 28661 || c.BlocksPerSequence != 79 || c.MaxConcurrentSequences != 362 {
+		t.Errorf("got %d blocks, %d per sequence, %d sequences; want 28661, 79, 362", c.NumBlocks, c.BlocksPerSequence, c.MaxConcurrentSequences)
+	}
+	// The last block is on average 7.5 tokens short of full.
+	if want := 7.5 / (79 * 16); !approxEqual(c.WasteFraction, want, 1e-12) {
+		t.Errorf("waste = %v, want %v", c.WasteFraction, want)
+	}
+}
+
+func TestGetServingCapacityOverBudget(t *testing.T) {
+	opts := ServingOptions{GPUMemoryUtilization: 0.9, BlockSize: 16, AvgPromptLength: 1000, MaxModelLen: 8192}
+	c := GetServingCapacity(llama3_70B, "bfloat16", 80*gib, opts)
+	if c.KVBudget != 0 || c.NumBlocks != 0 || c.MaxConcurrentSequences != 0 {
+		t.Errorf("over-budget capacity = %+v, want no blocks", c)
+	}
+}
+
+func TestServingCapacitySlidingWindow(t *testing.T) {
+	mistral := ModelSpec{VocabSize: 32000, HiddenSize: 4096, IntermediateSize: 14336, NumLayers: 32, NumHeads: 32, NumKVHeads: 8, HiddenAct: "silu"}
+	opts := ServingOptions{
+		GPUMemoryUtilization: 0.9,
+		BlockSize:            16,
+		AvgPromptLength:      30000,
+		AvgOutputLength:      2000,
+		MaxModelLen:          32768,
+		Activation:           ActivationOptions{FlashAttention: true},
+	}
+	tests := []struct {
+		name       string
+		window     int
+		layerTypes []string
+		perSeq     int
+	}{
+		{"full attention", 0, nil, 2000},
+		// Every layer stops at ⌈4096 / 16⌉ = 256 blocks.
+		{"all layers local", 4096, nil, 256},
+		// Gemma-2 alternates: (16 × 2000 + 16 × 256) / 32 = 1128.
+		{"alternating", 4096, alternatingLayers(32), 1128},
+		// A window longer than the conversation changes nothing.
+		{"window beyond the sequence", 65536, nil, 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := mistral
+			spec.SlidingWindow = tt.window
+			spec.LayerTypes = tt.layerTypes
+			c := GetServingCapacity(spec, "bfloat16", 80*gib, opts)
+			if c.BlocksPerSequence != tt.perSeq {
+				t.Errorf("blocks per sequence = %d, want %d", c.BlocksPerSequence, tt.perSeq)
+			}
+			if c.MaxConcurrentSequences != c.NumBlocks/tt.perSeq {
+				t.Errorf("%d sequences from %d blocks, want %d", c.MaxConcurrentSequences, c.NumBlocks, c.NumBlocks/tt.perSeq)
+			}
+			// The block count agrees with the calculator's own KV figure.
+			perSequence := GetKVCacheBreakdown(spec, 1, 32000, "bfloat16").Total
+			if got := float64(tt.perSeq) * c.KVBytesPerBlock; !approxEqual(got, perSequence, 1e-12) {
+				t.Errorf("%d blocks hold %.0f B, want %.0f B", tt.perSeq, got, perSequence)
+			}
+		})
+	}
+}
+
+func alternatingLayers(n int) []string {
+	types := make([]string, n)
+	for i := range types {
+		types[i] = LayerSlidingAttention
+		if i%2 == 1 {
+			types[i] = LayerFullAttention
+		}
+	}
+	return types
+}
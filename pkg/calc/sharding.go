@@ -0,0 +1,141 @@
+package calc
+
+import (
+	"compute-gauge/pkg/config"
+	"math"
+)
+
+const (
+	ShardDDP    = "ddp"
+	ShardZeRO1  = "zero1"
+	ShardZeRO2  = "zero2"
+	ShardZeRO3  = "zero3"
+	ShardFSDP   = "fsdp"
+	ShardHybrid = "hybrid"
+)
+
+const (
+	ddpBucketBytes          = 25 * 1024 * 1024
+	zeroBucketElements      = 5e8
+	zero3PrefetchLayers     = 2
+	DefaultHybridShardGroup = 8
+)
+
+var ShardingStrategies = map[string]bool{
+	ShardDDP:    true,
+	ShardZeRO1:  true,
+	ShardZeRO2:  true,
+	ShardZeRO3:  true,
+	ShardFSDP:   true,
+	ShardHybrid: true,
+}
+
+type ShardingOptions struct {
+	Strategy       string
+	DataParallel   int
+	ShardGroup     int
+	TensorParallel int
+}
+
+type TrainingFootprint struct {
+	Weights         float64
+	Gradients       float64
+	OptimizerStates float64
+	MasterWeights   float64
+	CommBuffers     float64
+	Activations     float64
+	KVCache         float64
+	Framework       float64
+	PerGPU          float64
+	// Host and NVMe hold offloaded state; the traffic fields are bytes moved per step.
+	Host        float64
+	NVMe        float64
+	PCIeTraffic float64
+	NVMeTraffic float64
+}
+
+func (m TrainingFootprint) Format() map[string]string {
+	formatted := map[string]string{
+		"weights":               FormatMemory(m.Weights),
+		"gradients":             FormatMemory(m.Gradients),
+		"optimizer_states":      FormatMemory(m.OptimizerStates),
+		"master_weights":        FormatMemory(m.MasterWeights),
+		"communication_buffers": FormatMemory(m.CommBuffers),
+		"activations":           FormatMemory(m.Activations),
+		"kv_cache":              FormatMemory(m.KVCache),
+		"per_gpu_memory":        FormatMemory(m.PerGPU),
+	}
+	if m.Framework > 0 {
+		formatted["framework_overhead"] = FormatMemory(m.Framework)
+	}
+	if m.Host+m.NVMe > 0 {
+		formatted["host_memory"] = FormatMemory(m.Host)
+		formatted["nvme_memory"] = FormatMemory(m.NVMe)
+		formatted["pcie_traffic_per_step"] = FormatMemory(m.PCIeTraffic)
+		formatted["nvme_traffic_per_step"] = FormatMemory(m.NVMeTraffic)
+	}
+	return formatted
+}
+
+func (s ShardingOptions) Enabled() bool {
+	return s.Strategy != ""
+}
+
+// Degrees returns how many ranks share the parameters, gradients and optimizer states.
+func (s ShardingOptions) Degrees() (float64, float64, float64) {
+	n := float64(s.DataParallel)
+	switch s.Strategy {
+	case ShardZeRO1:
+		return 1, 1, n
+	case ShardZeRO2:
+		return 1, n, n
+	case ShardZeRO3, ShardFSDP:
+		return n, n, n
+	case ShardHybrid:
+		group := s.ShardGroup
+		if group <= 0 {
+			group = DefaultHybridShardGroup
+		}
+		g := math.Min(float64(group), n)
+		return g, g, g
+	}
+	return 1, 1, 1
+}
+
+func (s ShardingOptions) shardsParameters() bool {
+	return s.Strategy == ShardZeRO3 || s.Strategy == ShardFSDP || s.Strategy == ShardHybrid
+}
+
+func GetCommBuffers(spec ModelSpec, s ShardingOptions, trainableParams float64, policy PrecisionPolicy) float64 {
+	weightBytes := config.BytesPerParam(policy.Weights)
+	gradBytes := config.BytesPerParam(policy.Gradients)
+	switch {
+	case s.Strategy == ShardDDP:
+		return 2 * ddpBucketBytes
+	case s.shardsParameters():
+		breakdown := CountParameters(spec)
+		layerParams := (spec.TotalParams() - breakdown.Embeddings - breakdown.LMHead) / float64(spec.NumLayers)
+		if s.TensorParallel > 1 {
+			layerParams /= float64(s.TensorParallel)
+		}
+		return zero3PrefetchLayers*layerParams*weightBytes + layerParams*gradBytes
+	default:
+		bucket := zeroBucketElements
+		if trainableParams < bucket {
+			bucket = trainableParams
+		}
+		return bucket * (gradBytes + weightBytes)
+	}
+}
+
+func (s ShardingOptions) Shard(spec ModelSpec, replica TrainingFootprint, trainableParams float64, policy PrecisionPolicy) TrainingFootprint {
+	paramDegree, gradDegree, optimDegree := s.Degrees()
+	m := replica
+	m.Weights /= paramDegree
+	m.Gradients /= gradDegree
+	m.OptimizerStates /= optimDegree
+	m.MasterWeights /= optimDegree
+	m.CommBuffers = GetCommBuffers(spec, s, trainableParams, policy)
+	m.PerGPU = m.Weights + m.Gradients + m.OptimizerStates + m.MasterWeights + m.CommBuffers + m.Activations + m.KVCache
+	return m
+}
@@ -0,0 +1,86 @@
+package calc
+
+import "testing"
+
+var llama3_70B = ModelSpec{
+	ModelSize:        70,
+	VocabSize:        128256,
+	HiddenSize:       8192,
+	IntermediateSize: 28672,
+	NumLayers:        80,
+	NumHeads:         64,
+	NumKVHeads:       8,
+	HiddenAct:        "silu",
+}
+
+func TestShardingDegrees(t *testing.T) {
+	tests := []struct {
+		opts                        ShardingOptions
+		params, grads, optimizerDeg float64
+	}{
+		{ShardingOptions{Strategy: ShardDDP, DataParallel: 8}, 1, 1, 1},
+		{ShardingOptions{Strategy: ShardZeRO1, DataParallel: 8}, 1, 1, 8},
+		{ShardingOptions{Strategy: ShardZeRO2, DataParallel: 8}, 1, 8, 8},
+		{ShardingOptions{Strategy: ShardZeRO3, DataParallel: 8}, 8, 8, 8},
+		{ShardingOptions{Strategy: ShardFSDP, DataParallel: 16}, 16, 16, 16},
+		// Hybrid shards inside a group and replicates across groups.
+		{ShardingOptions{Strategy: ShardHybrid, DataParallel: 16, ShardGroup: 4}, 4, 4, 4},
+		// An unset group falls back to DefaultHybridShardGroup.
+		{ShardingOptions{Strategy: ShardHybrid, DataParallel: 16}, 8, 8, 8},
+		{ShardingOptions{Strategy: ShardHybrid, DataParallel: 4}, 4, 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opts.Strategy, func(t *testing.T) {
+			p, g, o := tt.opts.Degrees()
+			if p != tt.params || g != tt.grads || o != tt.optimizerDeg {
+				t.Errorf("degrees = (%v, %v, %v), want (%v, %v, %v)", p, g, o, tt.params, tt.grads, tt.optimizerDeg)
+			}
+		})
+	}
+}
+
+func TestShardPerGPU(t *testing.T) {
+	// Llama-3-70B has 70,553,706,496 parameters; mixed-precision AdamW keeps
+	// 2 + 4 + 4 + 8 = 18 bytes of each.
+	const params = 70553706496.0
+	policy := PrecisionPolicy{}.WithDefaults("bfloat16")
+	replica := TrainingFootprint{
+		Weights:         2 * params,
+		Gradients:       4 * params,
+		MasterWeights:   4 * params,
+		OptimizerStates: 8 * params,
+	}
+	// A fully sharded rank holds two prefetched bf16 layers and one fp32
+	// layer gradient: 8 bytes × 855,654,502.4 parameters per layer.
+	const layerBuffers = 8 * (params - 2*128256*8192) / 80
+	// ZeRO-1/2 reduce-scatter and all-gather buckets of 5e8 elements.
+	const zeroBuckets = 5e8 * (4 + 2)
+	tests := []struct {
+		opts ShardingOptions
+		want float64
+	}{
+		{ShardingOptions{Strategy: ShardDDP, DataParallel: 8}, 18*params + 2*25*1024*1024},
+		{ShardingOptions{Strategy: ShardZeRO1, DataParallel: 8}, (2+4+12.0/8)*params + zeroBuckets},
+		{ShardingOptions{Strategy: ShardZeRO2, DataParallel: 8}, (2+16.0/8)*params + zeroBuckets},
+		// About 154.2 GiB per GPU.
+		{ShardingOptions{Strategy: ShardZeRO3, DataParallel: 8}, 18.0/8*params + layerBuffers},
+		{ShardingOptions{Strategy: ShardHybrid, DataParallel: 16, ShardGroup: 4}, 18.0/4*params + layerBuffers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opts.Strategy, func(t *testing.T) {
+			got := tt.opts.Shard(llama3_70B, replica, params, policy)
+			if !approxEqual(got.PerGPU, tt.want, 1e-12) {
+				t.Errorf("per-GPU memory = %s, want %s", FormatMemory(got.PerGPU), FormatMemory(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetCommBuffersSmallModel(t *testing.T) {
+	// ZeRO buckets never exceed the trainable parameters themselves.
+	policy := PrecisionPolicy{}.WithDefaults("bfloat16")
+	opts := ShardingOptions{Strategy: ShardZeRO2, DataParallel: 8}
+	if got, want := GetCommBuffers(llama2_7B, opts, 1e8, policy), 1e8*6.0; got != want {
+		t.Errorf("comm buffers = %.0f, want %.0f", got, want)
+	}
+}
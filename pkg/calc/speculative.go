@@ -0,0 +1,60 @@
+package calc
+
+import "math"
+
+const DefaultLookahead = 4
+
+type SpeculativeOptions struct {
+	Lookahead      int
+	DraftPrecision string
+	DraftKVCache   KVCacheOptions
+}
+
+type SpeculativeFootprint struct {
+	DraftWeights       float64
+	DraftKVCache       float64
+	LookaheadKVCache   float64
+	VerifyActivations  float64
+	PrefillActivations float64
+}
+
+// Total counts only the verify activations beyond the prefill reservation they reuse.
+func (f SpeculativeFootprint) Total() float64 {
+	return f.DraftWeights + f.DraftKVCache + f.LookaheadKVCache + math.Max(0, f.VerifyActivations-f.PrefillActivations)
+}
+
+func (f SpeculativeFootprint) Format() map[string]string {
+	return map[string]string{
+		"draft_weights":      FormatMemory(f.DraftWeights),
+		"draft_kv_cache":     FormatMemory(f.DraftKVCache),
+		"lookahead_kv_cache": FormatMemory(f.LookaheadKVCache),
+		"verify_activations": FormatMemory(f.VerifyActivations),
+	}
+}
+
+// GetSpeculativeFootprint has the draft cache the same sequences Lookahead tokens ahead.
+func GetSpeculativeFootprint(target, draft ModelSpec, precision string, batchSize, seqLength int, act ActivationOptions, kv KVCacheOptions, opts SpeculativeOptions) SpeculativeFootprint {
+	dtype := kv.WithDefaults(precision).Dtype
+	draftDtype := opts.DraftKVCache.WithDefaults(opts.DraftPrecision).Dtype
+	lookahead := seqLength + opts.Lookahead
+	draftKV, _ := opts.DraftKVCache.Split(GetKVCacheBreakdown(draft, batchSize, lookahead, draftDtype).Total)
+	ssmState, convState := GetSSMState(draft, batchSize, opts.DraftPrecision)
+	extraKV := GetKVCacheBreakdown(target, batchSize, lookahead, dtype).Total - GetKVCacheBreakdown(target, batchSize, seqLength, dtype).Total
+	extraKV, _ = kv.Split(extraKV)
+	return SpeculativeFootprint{
+		DraftWeights:       GetWeightFootprint(draft, opts.DraftPrecision),
+		DraftKVCache:       draftKV + ssmState + convState,
+		LookaheadKVCache:   extraKV,
+		VerifyActivations:  GetActivationBreakdown(target, precision, batchSize, opts.Lookahead+1, false, act).Total,
+		PrefillActivations: GetActivationBreakdown(target, precision, batchSize, seqLength, false, act).Total,
+	}
+}
+
+// GetExpectedTokens is (1 − α^(k+1)) / (1 − α) (Leviathan et al., 2023).
+func GetExpectedTokens(acceptanceRate float64, lookahead int) float64 {
+	k := float64(lookahead)
+	if acceptanceRate >= 1 {
+		return k + 1
+	}
+	return (1 - math.Pow(acceptanceRate, k+1)) / (1 - acceptanceRate)
+}
@@ -0,0 +1,55 @@
+package calc
+
+import "testing"
+
+// Expected tokens per cycle from Leviathan et al. (2023), equation 1.
+func TestGetExpectedTokens(t *testing.T) {
+	tests := []struct {
+		alpha     float64
+		lookahead int
+		want      float64
+	}{
+		{0.7, 4, 2.7731},
+		{0.8, 4, 3.3616},
+		{0.6, 2, 1.96},
+		{0.5, 1, 1.5},
+		{1, 4, 5},
+		{0, 4, 1},
+	}
+	for _, tt := range tests {
+		got := GetExpectedTokens(tt.alpha, tt.lookahead)
+		if !approxEqual(got, tt.want, 1e-9) {
+			t.Errorf("GetExpectedTokens(%v, %d) = %v, want %v", tt.alpha, tt.lookahead, got, tt.want)
+		}
+	}
+}
+
+func TestSpeculativeVerifyReusesPrefillActivations(t *testing.T) {
+	opts := SpeculativeOptions{Lookahead: 4, DraftPrecision: "bfloat16"}
+	tests := []struct {
+		name       string
+		seqLength  int
+		addsVerify bool
+	}{
+		// Prefill over the whole context dwarfs a five-token verify pass.
+		{"long context", 4096, false},
+		// A two-token prompt prefills less than the verify pass scores.
+		{"short prompt", 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := GetSpeculativeFootprint(llama3_8B, llama2_7B, "bfloat16", 1, tt.seqLength, ActivationOptions{}, KVCacheOptions{}, opts)
+			cache := f.DraftWeights + f.DraftKVCache + f.LookaheadKVCache
+			want := cache
+			if tt.addsVerify {
+				want += f.VerifyActivations - f.PrefillActivations
+			}
+			if got := f.Total(); got != want {
+				t.Errorf("Total = %s, want %s", FormatMemory(got), FormatMemory(want))
+			}
+			if tt.addsVerify && f.Total() <= cache {
+				t.Errorf("verify pass of %s adds nothing over a prefill of %s", FormatMemory(f.VerifyActivations), FormatMemory(f.PrefillActivations))
+			}
+		})
+	}
+}
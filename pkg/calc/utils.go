@@ -30,82 +30,213 @@ func FormatMemory(bytes float64) string {
 	}
 }
 func GetModelWeights(modelSize float64, precision string) float64 {
-	if size, ok := config.DataTypeSizes[precision]; ok {
+	if scheme, ok := config.QuantSchemes[precision]; ok {
 		params := modelSize * math.Pow(10, 9)
-		return params * float64(size)
+		return params * scheme.BytesPerParam()
 	}
 	return 0
 }
-func GetKVCache(batchSize, seqLength, numLayers, hiddenSize int, precision string) float64 {
-	if size, ok := config.DataTypeSizes[precision]; ok {
-		batchF := float64(batchSize)
-		seqF := float64(seqLength)
-		layersF := float64(numLayers)
-		hiddenF := float64(hiddenSize)
-		sizeF := float64(size)
-		return 2.0 * batchF * seqF * layersF * hiddenF * sizeF
+func GetHeadDim(hiddenSize, numHeads int) int {
+	if numHeads <= 0 {
+		return 0
 	}
-	return 0
+	return hiddenSize / numHeads
 }
-func GetActivationMemory(batchSize, seqLength, numLayers, hiddenSize, numHeads int, precision string) float64 {
-	const activationPrecision = "float32"
-	if size, ok := config.DataTypeSizes[activationPrecision]; ok {
-		batchF := float64(batchSize)
-		seqF := float64(seqLength)
-		headsF := float64(numHeads)
-		hiddenF := float64(hiddenSize)
-		sizeF := float64(size)
-		activationFactor := 34.0 + ((5.0 * seqF * headsF) / hiddenF)
-		return batchF * seqF * hiddenF * activationFactor * sizeF
-	}
-	return 0
+func GetKVCache(batchSize, seqLength, numLayers, numKVHeads, headDim int, dtype string) float64 {
+	spec := ModelSpec{NumLayers: numLayers, NumKVHeads: numKVHeads, HeadSize: headDim}
+	return GetKVCacheBreakdown(spec, batchSize, seqLength, dtype).Total
 }
-func GetOptimizerMemory(trainableParams float64, optimizer string) float64 {
+func GetOptimizerMemory(trainableParams float64, optimizer string, policy PrecisionPolicy, factoredFraction float64) float64 {
 	actualParams := trainableParams * math.Pow(10, 9)
-	switch optimizer {
-	case "AdamW", "Adam":
-		return actualParams * 8.0
-	case "QAdamW":
-		return actualParams * 2.0
-	case "SGD":
-		return actualParams * 4.0
-	default:
-		return 0
-	}
+	return actualParams * policy.OptimizerStateBytes(optimizer, factoredFraction)
+}
+func GetGradientMemory(trainableParams float64, policy PrecisionPolicy) float64 {
+	actualParams := trainableParams * math.Pow(10, 9)
+	return actualParams * config.BytesPerParam(policy.Gradients)
 }
-func GetGradientMemory(trainableParams float64) float64 {
+func GetMasterWeightsMemory(trainableParams float64, policy PrecisionPolicy) float64 {
 	actualParams := trainableParams * math.Pow(10, 9)
-	return actualParams * 4.0
-}
-func CalculateInferenceMemory(modelSize float64, precision string, batchSize, seqLength, hiddenSize, numLayers, numHeads int) map[string]string {
-	modelWeights := GetModelWeights(modelSize, precision)
-	kvCache := GetKVCache(batchSize, seqLength, numLayers, hiddenSize, precision)
-	activationMem := GetActivationMemory(batchSize, seqLength, numLayers, hiddenSize, numHeads, precision)
-	totalMem := modelWeights + kvCache + activationMem
-	return map[string]string{
-		"model_weights":     FormatMemory(modelWeights),
-		"kv_cache":          FormatMemory(kvCache),
-		"activation_memory": FormatMemory(activationMem),
-		"inference_memory":  FormatMemory(totalMem),
+	return actualParams * policy.MasterBytes()
+}
+func calculateBaseMemory(spec ModelSpec, precision string, batchSize, seqLength int, training bool, act ActivationOptions, kv KVCacheOptions) (float64, float64, ActivationBreakdown) {
+	dtype := kv.WithDefaults(precision).Dtype
+	modelWeights := GetWeightFootprint(spec, precision) + GetComponentWeights(spec)
+	kvCache := GetKVCacheBreakdown(spec, batchSize, seqLength, dtype).Total + GetCrossAttentionKV(spec, batchSize, dtype)
+	activations := GetActivationBreakdown(spec, precision, batchSize, seqLength, training, act)
+	return modelWeights, kvCache, activations
+}
+
+// InferenceMemory excludes framework overhead.
+type InferenceMemory struct {
+	Weights          float64
+	KVCache          float64
+	KVCacheGPU       float64
+	KVCacheHost      float64
+	SSMState         float64
+	ConvState        float64
+	Activations      float64
+	ComponentWeights float64
+	CrossAttentionKV float64
+	ExpertWeights    float64
+	Router           float64
+}
+
+func (m InferenceMemory) Total() float64 {
+	return m.Weights + m.KVCacheGPU + m.SSMState + m.ConvState + m.Activations
+}
+
+func (m InferenceMemory) Format() map[string]string {
+	formatted := map[string]string{
+		"model_weights":     FormatMemory(m.Weights),
+		"kv_cache":          FormatMemory(m.KVCache),
+		"kv_cache_gpu":      FormatMemory(m.KVCacheGPU),
+		"kv_cache_host":     FormatMemory(m.KVCacheHost),
+		"activation_memory": FormatMemory(m.Activations),
+		"inference_memory":  FormatMemory(m.Total()),
 	}
+	formatExtras(formatted, m.SSMState, m.ConvState, m.ComponentWeights, m.CrossAttentionKV, m.ExpertWeights, m.Router)
+	return formatted
+}
+
+// TrainingMemory is one unsharded replica, excluding framework overhead.
+type TrainingMemory struct {
+	BaseWeights      float64
+	AdapterWeights   float64
+	KVCache          float64
+	SSMState         float64
+	ConvState        float64
+	Activations      float64
+	Optimizer        float64
+	Gradients        float64
+	MasterWeights    float64
+	ComponentWeights float64
+	CrossAttentionKV float64
+	ExpertWeights    float64
+	Router           float64
+	LoRA             bool
+}
+
+func (m TrainingMemory) Weights() float64 {
+	return m.BaseWeights + m.AdapterWeights
+}
+
+// State is the memory the optimizer step adds on top of a forward pass.
+func (m TrainingMemory) State() float64 {
+	return m.Optimizer + m.Gradients + m.MasterWeights
 }
 
-func CalculateTrainingMemory(modelSize float64, precision string, batchSize, seqLength, hiddenSize, numLayers, numHeads int, optimizer string, trainableParams float64) map[string]string {
-	modelWeights := GetModelWeights(modelSize, precision)
-	kvCache := GetKVCache(batchSize, seqLength, numLayers, hiddenSize, precision)
-	activationMem := GetActivationMemory(batchSize, seqLength, numLayers, hiddenSize, numHeads, precision)
-	inferenceMem := modelWeights + kvCache + activationMem
-	optimizerMem := GetOptimizerMemory(trainableParams, optimizer)
-	gradientMem := GetGradientMemory(trainableParams)
-	trainingSpecificMem := optimizerMem + gradientMem
-	totalMem := inferenceMem + trainingSpecificMem
-	return map[string]string{
-		"model_weights":     FormatMemory(modelWeights),
-		"kv_cache":          FormatMemory(kvCache),
-		"activation_memory": FormatMemory(activationMem),
-		"optimizer_memory":  FormatMemory(optimizerMem),
-		"gradients_memory":  FormatMemory(gradientMem),
-		"inference_memory":  FormatMemory(inferenceMem),
-		"training_memory":   FormatMemory(totalMem),
+func (m TrainingMemory) Inference() float64 {
+	return m.Weights() + m.KVCache + m.SSMState + m.ConvState + m.Activations
+}
+
+func (m TrainingMemory) Total() float64 {
+	return m.Inference() + m.State()
+}
+
+func (m TrainingMemory) Format() map[string]string {
+	formatted := map[string]string{
+		"model_weights":     FormatMemory(m.Weights()),
+		"kv_cache":          FormatMemory(m.KVCache),
+		"activation_memory": FormatMemory(m.Activations),
+		"optimizer_memory":  FormatMemory(m.Optimizer),
+		"gradients_memory":  FormatMemory(m.Gradients),
+		"master_weights":    FormatMemory(m.MasterWeights),
+		"inference_memory":  FormatMemory(m.Inference()),
+		"training_memory":   FormatMemory(m.Total()),
+	}
+	if m.LoRA {
+		formatted["base_weights"] = FormatMemory(m.BaseWeights)
+		formatted["adapter_weights"] = FormatMemory(m.AdapterWeights)
+	}
+	formatExtras(formatted, m.SSMState, m.ConvState, m.ComponentWeights, m.CrossAttentionKV, m.ExpertWeights, m.Router)
+	return formatted
+}
+
+func formatExtras(formatted map[string]string, ssmState, convState, componentWeights, crossKV, expertWeights, router float64) {
+	if ssmState+convState > 0 {
+		formatted["ssm_state"] = FormatMemory(ssmState)
+		formatted["conv_state"] = FormatMemory(convState)
+	}
+	if componentWeights > 0 {
+		formatted["component_weights"] = FormatMemory(componentWeights)
+	}
+	if crossKV > 0 {
+		formatted["cross_attention_kv"] = FormatMemory(crossKV)
+	}
+	if expertWeights > 0 {
+		formatted["expert_weights"] = FormatMemory(expertWeights)
+		formatted["router_memory"] = FormatMemory(router)
+	}
+}
+
+func getExpertWeights(spec ModelSpec, precision string) float64 {
+	if !spec.IsMoE() {
+		return 0
+	}
+	return GetModelWeights(GetExpertParams(spec, spec.NumExperts)/math.Pow(10, 9), precision)
+}
+
+func CalculateInferenceMemory(spec ModelSpec, precision string, batchSize, seqLength int, act ActivationOptions, kv KVCacheOptions) (InferenceMemory, ActivationBreakdown) {
+	modelWeights, kvCache, activations := calculateBaseMemory(spec, precision, batchSize, seqLength, false, act, kv)
+	kvGPU, kvHost := kv.Split(kvCache)
+	ssmState, convState := GetSSMState(spec, batchSize, precision)
+	return InferenceMemory{
+		Weights:          modelWeights,
+		KVCache:          kvCache,
+		KVCacheGPU:       kvGPU,
+		KVCacheHost:      kvHost,
+		SSMState:         ssmState,
+		ConvState:        convState,
+		Activations:      activations.Total,
+		ComponentWeights: GetComponentWeights(spec),
+		CrossAttentionKV: GetCrossAttentionKV(spec, batchSize, kv.WithDefaults(precision).Dtype),
+		ExpertWeights:    getExpertWeights(spec, precision),
+		Router:           activations.Router,
+	}, activations
+}
+
+// CalculateTrainingMemory sets the per-GPU footprint only when sharding or offloading.
+func CalculateTrainingMemory(spec ModelSpec, precision string, batchSize, seqLength int, opts TrainingOptions) (TrainingMemory, ActivationBreakdown, TrainingFootprint) {
+	_, kvCache, activations := calculateBaseMemory(spec, precision, batchSize, seqLength, true, opts.Activation, opts.KVCache)
+	policy := opts.Policy.WithDefaults(precision)
+	baseWeights, adapterWeights := GetTrainingWeights(spec, policy.Weights, opts)
+	ssmState, convState := GetSSMState(spec, batchSize, precision)
+	trainableParams := opts.TrainableParams(spec) / math.Pow(10, 9)
+	memory := TrainingMemory{
+		BaseWeights:      baseWeights,
+		AdapterWeights:   adapterWeights,
+		KVCache:          kvCache,
+		SSMState:         ssmState,
+		ConvState:        convState,
+		Activations:      activations.Total,
+		Optimizer:        GetOptimizerMemory(trainableParams, opts.Optimizer, policy, opts.FactoredStateFraction(spec)),
+		Gradients:        GetGradientMemory(trainableParams, policy),
+		MasterWeights:    GetMasterWeightsMemory(trainableParams, policy),
+		ComponentWeights: GetComponentWeights(spec),
+		CrossAttentionKV: GetCrossAttentionKV(spec, batchSize, opts.KVCache.WithDefaults(precision).Dtype),
+		ExpertWeights:    getExpertWeights(spec, precision),
+		Router:           activations.Router,
+		LoRA:             opts.IsLoRA(),
+	}
+	var sharded TrainingFootprint
+	if opts.Sharding.Enabled() || opts.Offload.Enabled() {
+		sharded = TrainingFootprint{
+			Weights:         memory.Weights(),
+			Gradients:       memory.Gradients,
+			OptimizerStates: memory.Optimizer,
+			MasterWeights:   memory.MasterWeights,
+			Activations:     memory.Activations,
+			KVCache:         kvCache + ssmState + convState,
+		}
+		if opts.Sharding.Enabled() {
+			sharded = opts.Sharding.Shard(spec, sharded, trainableParams*math.Pow(10, 9), policy)
+		}
+		if opts.Offload.Enabled() {
+			_, _, optimDegree := opts.Sharding.Degrees()
+			updatedWeights := trainableParams * math.Pow(10, 9) * config.BytesPerParam(policy.Weights) / optimDegree
+			sharded = opts.Offload.Apply(sharded, updatedWeights)
+		}
+		sharded = sharded.WithOverhead(opts.Overhead)
 	}
+	return memory, activations, sharded
 }
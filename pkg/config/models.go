@@ -9,23 +9,161 @@ import (
 	"strings"
 )
 
-var DataTypeSizes = map[string]float64{
-	"float32":  4.0,
-	"float16":  2.0,
-	"bfloat16": 2.0,
-	"int8":     1.0,
-	"int4":     0.5,
+type ModelConfig struct {
+	Name                 string              `json:"name"`
+	ModelSize            float64             `json:"model_size"`
+	VocabSize            int                 `json:"vocab_size"`
+	HiddenSize           int                 `json:"hidden_size"`
+	IntermediateSize     int                 `json:"intermediate_size"`
+	NumHiddenLayers      int                 `json:"num_hidden_layers"`
+	NumAttentionHeads    int                 `json:"num_attention_heads"`
+	NumKeyValueHeads     int                 `json:"num_key_value_heads"`
+	HeadDim              int                 `json:"head_dim,omitempty"`
+	HiddenAct            string              `json:"hidden_act,omitempty"`
+	TieWordEmbeddings    bool                `json:"tie_word_embeddings"`
+	AttentionBias        bool                `json:"attention_bias"`
+	MLPBias              bool                `json:"mlp_bias"`
+	NumLocalExperts      int                 `json:"num_local_experts,omitempty"`
+	NumExpertsPerTok     int                 `json:"num_experts_per_tok,omitempty"`
+	SlidingWindow        int                 `json:"sliding_window,omitempty"`
+	UseSlidingWindow     *bool               `json:"use_sliding_window,omitempty"`
+	MaxWindowLayers      int                 `json:"max_window_layers,omitempty"`
+	SlidingWindowPattern int                 `json:"sliding_window_pattern,omitempty"`
+	LayerTypes           []string            `json:"layer_types,omitempty"`
+	KVLoRARank           int                 `json:"kv_lora_rank,omitempty"`
+	QLoRARank            int                 `json:"q_lora_rank,omitempty"`
+	QKRopeHeadDim        int                 `json:"qk_rope_head_dim,omitempty"`
+	QKNopeHeadDim        int                 `json:"qk_nope_head_dim,omitempty"`
+	VHeadDim             int                 `json:"v_head_dim,omitempty"`
+	AttentionVariant     string              `json:"attention_variant,omitempty"`
+	LayersBlockType      []string            `json:"layers_block_type,omitempty"`
+	AttnLayerPeriod      int                 `json:"attn_layer_period,omitempty"`
+	AttnLayerOffset      int                 `json:"attn_layer_offset,omitempty"`
+	ExpertLayerPeriod    int                 `json:"expert_layer_period,omitempty"`
+	ExpertLayerOffset    int                 `json:"expert_layer_offset,omitempty"`
+	MambaDState          int                 `json:"mamba_d_state,omitempty"`
+	MambaDConv           int                 `json:"mamba_d_conv,omitempty"`
+	MambaExpand          int                 `json:"mamba_expand,omitempty"`
+	MambaDTRank          int                 `json:"mamba_dt_rank,omitempty"`
+	SequenceLength       int                 `json:"max_position_embeddings"`
+	Precision            string              `json:"torch_dtype"`
+	Quantization         *QuantizationConfig `json:"quantization_config,omitempty"`
+	Encoder              *ComponentConfig    `json:"encoder,omitempty"`
+	VisionConfig         *ComponentConfig    `json:"vision_config,omitempty"`
+	ImageSeqLength       int                 `json:"image_seq_length,omitempty"`
+	// Vision-language configs nest the language model under text_config.
+	TextConfig *ModelConfig `json:"text_config,omitempty"`
+	// Alternative spellings that normalizeNames folds into the fields above.
+	StateSize    int `json:"state_size,omitempty"`
+	ConvKernel   int `json:"conv_kernel,omitempty"`
+	Expand       int `json:"expand,omitempty"`
+	TimeStepRank int `json:"time_step_rank,omitempty"`
+	NumExperts   int `json:"num_experts,omitempty"`
+	// T5 names its dimensions after the paper; normalizeT5 maps them.
+	DModel           int    `json:"d_model,omitempty"`
+	DFF              int    `json:"d_ff,omitempty"`
+	DKV              int    `json:"d_kv,omitempty"`
+	NumHeads         int    `json:"num_heads,omitempty"`
+	NumLayers        int    `json:"num_layers,omitempty"`
+	NumDecoderLayers int    `json:"num_decoder_layers,omitempty"`
+	FeedForwardProj  string `json:"feed_forward_proj,omitempty"`
 }
 
-type ModelConfig struct {
-	Name              string  `json:"name"`
-	ModelSize         float64 `json:"model_size"`
-	HiddenSize        int     `json:"hidden_size"`
-	NumHiddenLayers   int     `json:"num_hidden_layers"`
-	NumAttentionHeads int     `json:"num_attention_heads"`
-	NumKeyValueHeads  int     `json:"num_key_value_heads"`
-	SequenceLength    int     `json:"max_position_embeddings"`
-	Precision         string  `json:"torch_dtype"`
+// ComponentConfig sizes the encoder of an encoder-decoder or the vision tower of a VLM.
+type ComponentConfig struct {
+	HiddenSize        int    `json:"hidden_size"`
+	IntermediateSize  int    `json:"intermediate_size"`
+	NumHiddenLayers   int    `json:"num_hidden_layers"`
+	NumAttentionHeads int    `json:"num_attention_heads"`
+	NumKeyValueHeads  int    `json:"num_key_value_heads,omitempty"`
+	HeadDim           int    `json:"head_dim,omitempty"`
+	HiddenAct         string `json:"hidden_act,omitempty"`
+	TorchDtype        string `json:"torch_dtype,omitempty"`
+	ImageSize         int    `json:"image_size,omitempty"`
+	PatchSize         int    `json:"patch_size,omitempty"`
+	Trainable         *bool  `json:"trainable,omitempty"`
+}
+
+const (
+	AttentionMHA = "mha"
+	AttentionGQA = "gqa"
+	AttentionMQA = "mqa"
+	AttentionMLA = "mla"
+	// AttentionNone marks pure state-space models.
+	AttentionNone = "none"
+)
+
+// DetectAttentionVariant names the attention layout implied by the head counts and kv_lora_rank.
+func DetectAttentionVariant(numHeads, numKVHeads, kvLoRARank int) string {
+	switch {
+	case numHeads <= 0:
+		return AttentionNone
+	case kvLoRARank > 0:
+		return AttentionMLA
+	case numKVHeads == 1:
+		return AttentionMQA
+	case numKVHeads > 0 && numKVHeads < numHeads:
+		return AttentionGQA
+	}
+	return AttentionMHA
+}
+
+func (c *ModelConfig) normalizeNames() {
+	if c.TextConfig != nil && c.HiddenSize == 0 {
+		text := *c.TextConfig
+		text.normalizeNames()
+		text.Name, text.ModelSize = c.Name, c.ModelSize
+		text.VisionConfig, text.ImageSeqLength = c.VisionConfig, c.ImageSeqLength
+		if text.Precision == "" {
+			text.Precision = c.Precision
+		}
+		*c = text
+	}
+	if c.DModel > 0 && c.HiddenSize == 0 {
+		c.normalizeT5()
+	}
+	if c.MambaDState == 0 {
+		c.MambaDState = c.StateSize
+	}
+	if c.MambaDConv == 0 {
+		c.MambaDConv = c.ConvKernel
+	}
+	if c.MambaExpand == 0 {
+		c.MambaExpand = c.Expand
+	}
+	if c.MambaDTRank == 0 {
+		c.MambaDTRank = c.TimeStepRank
+	}
+	if c.NumLocalExperts == 0 {
+		c.NumLocalExperts = c.NumExperts
+	}
+}
+
+// normalizeT5 maps a T5 config onto the decoder fields plus an encoder of the same width.
+func (c *ModelConfig) normalizeT5() {
+	act := strings.TrimPrefix(c.FeedForwardProj, "gated-")
+	if act == "" {
+		act = "relu"
+	} else if act != c.FeedForwardProj {
+		act = "geglu"
+	}
+	decoderLayers := c.NumDecoderLayers
+	if decoderLayers == 0 {
+		decoderLayers = c.NumLayers
+	}
+	c.HiddenSize, c.IntermediateSize, c.HeadDim, c.HiddenAct = c.DModel, c.DFF, c.DKV, act
+	c.NumHiddenLayers, c.NumAttentionHeads, c.NumKeyValueHeads = decoderLayers, c.NumHeads, c.NumHeads
+	if c.Encoder == nil {
+		c.Encoder = &ComponentConfig{
+			HiddenSize:        c.DModel,
+			IntermediateSize:  c.DFF,
+			NumHiddenLayers:   c.NumLayers,
+			NumAttentionHeads: c.NumHeads,
+			NumKeyValueHeads:  c.NumHeads,
+			HeadDim:           c.DKV,
+			HiddenAct:         act,
+		}
+	}
 }
 
 type MemoryRequest struct {
@@ -120,6 +258,8 @@ func LoadModelConfigs() (map[string]ModelConfig, error) {
 			if config.Name == "" {
 				config.Name = modelName
 			}
+			config.normalizeNames()
+			config.AttentionVariant = DetectAttentionVariant(config.NumAttentionHeads, config.NumKeyValueHeads, config.KVLoRARank)
 			models[modelName] = config
 			log.Printf("Loaded model: %s", modelName)
 		}
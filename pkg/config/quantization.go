@@ -0,0 +1,132 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// UnquantizedDtype is the precision schemes keep their excluded layers and norms in.
+const UnquantizedDtype = "bfloat16"
+
+// QuantScheme stores Bits per weight plus a scale and zero point per GroupSize weights.
+type QuantScheme struct {
+	Bits        float64  `json:"bits"`
+	GroupSize   int      `json:"group_size,omitempty"`
+	ScaleBits   float64  `json:"scale_bits,omitempty"`
+	ZeroBits    float64  `json:"zero_bits,omitempty"`
+	Unquantized []string `json:"unquantized,omitempty"`
+}
+
+var QuantSchemes = map[string]QuantScheme{
+	"float32":  {Bits: 32},
+	"float16":  {Bits: 16},
+	"bfloat16": {Bits: 16},
+	// Weight-only integers with one fp16 scale per 128 weights.
+	"int8": {Bits: 8, GroupSize: 128, ScaleBits: 16, Unquantized: []string{"embed_tokens", "lm_head"}},
+	"int4": {Bits: 4, GroupSize: 128, ScaleBits: 16, Unquantized: []string{"embed_tokens", "lm_head"}},
+	// bitsandbytes LLM.int8() keeps one fp32 absmax per weight row, costed
+	// at 4096 inputs per row. Outliers are split from the activations at
+	// run time, so the stored weights stay int8.
+	"bnb_int8": {Bits: 8, GroupSize: 4096, ScaleBits: 32, Unquantized: []string{"embed_tokens", "lm_head"}},
+	// FP8 E4M3 with one fp32 scale per tensor.
+	"fp8": {Bits: 8, Unquantized: []string{"embed_tokens", "lm_head"}},
+	// GPTQ and AWQ store an fp16 scale and a packed 4-bit zero point per
+	// group of 128 input channels.
+	"gptq_int4_g128": {Bits: 4, GroupSize: 128, ScaleBits: 16, ZeroBits: 4, Unquantized: []string{"embed_tokens", "lm_head"}},
+	"awq_int4_g128":  {Bits: 4, GroupSize: 128, ScaleBits: 16, ZeroBits: 4, Unquantized: []string{"embed_tokens", "lm_head"}},
+	// bitsandbytes NF4 with double quantization: the per-64 absmax is
+	// itself quantized to 8 bits with one fp32 constant per 256 blocks.
+	"nf4": {Bits: 4, GroupSize: 64, ScaleBits: 8 + 32.0/256, Unquantized: []string{"embed_tokens", "lm_head"}},
+	// OCP microscaling FP4 (E2M1) with a shared E8M0 exponent per 32.
+	"mxfp4": {Bits: 4, GroupSize: 32, ScaleBits: 8, Unquantized: []string{"embed_tokens", "lm_head"}},
+	// llama.cpp Q4_K: 6-bit scale and min per 32-weight sub-block plus an
+	// fp16 scale and min per 256-weight super-block, folded in as 2 bits
+	// each. Q4_K_M keeps the output layer at Q6_K, costed here as 16-bit.
+	"gguf_q4_k_m": {Bits: 4, GroupSize: 32, ScaleBits: 6 + 2, ZeroBits: 6 + 2, Unquantized: []string{"lm_head"}},
+}
+
+func (q QuantScheme) BitsPerParam() float64 {
+	bits := q.Bits
+	if q.GroupSize > 0 {
+		bits += (q.ScaleBits + q.ZeroBits) / float64(q.GroupSize)
+	}
+	return bits
+}
+
+func (q QuantScheme) BytesPerParam() float64 {
+	return q.BitsPerParam() / 8
+}
+
+// IsQuantized reports whether the scheme is a weight-only format rather than a plain dtype.
+func (q QuantScheme) IsQuantized() bool {
+	return q.GroupSize > 0 || len(q.Unquantized) > 0
+}
+
+// BytesPerParam returns the storage cost of one parameter, or 0 for an unknown dtype.
+func BytesPerParam(dtype string) float64 {
+	return QuantSchemes[dtype].BytesPerParam()
+}
+
+func QuantSchemeNames() []string {
+	names := make([]string, 0, len(QuantSchemes))
+	for name := range QuantSchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PlainDtypeNames lists the dtypes gradients and optimizer states can use.
+func PlainDtypeNames() []string {
+	var names []string
+	for _, name := range QuantSchemeNames() {
+		if !QuantSchemes[name].IsQuantized() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// QuantizationConfig is the part of a model file's quantization_config that sizes the weights.
+type QuantizationConfig struct {
+	QuantMethod         string   `json:"quant_method"`
+	Bits                int      `json:"bits,omitempty"`
+	GroupSize           int      `json:"group_size,omitempty"`
+	LoadIn8bit          bool     `json:"load_in_8bit,omitempty"`
+	LoadIn4bit          bool     `json:"load_in_4bit,omitempty"`
+	ModulesToNotConvert []string `json:"modules_to_not_convert,omitempty"`
+	LLMInt8SkipModules  []string `json:"llm_int8_skip_modules,omitempty"`
+}
+
+// Scheme maps the quantization method to a registered scheme.
+func (q QuantizationConfig) Scheme() (string, bool) {
+	switch strings.ToLower(q.QuantMethod) {
+	case "fbgemm_fp8", "fp8":
+		return "fp8", true
+	case "gptq":
+		if q.Bits == 4 && q.GroupSize == 128 {
+			return "gptq_int4_g128", true
+		}
+	case "awq":
+		if q.Bits == 4 && q.GroupSize == 128 {
+			return "awq_int4_g128", true
+		}
+	case "bitsandbytes":
+		if q.LoadIn4bit {
+			return "nf4", true
+		}
+		if q.LoadIn8bit {
+			return "bnb_int8", true
+		}
+	case "mxfp4":
+		return "mxfp4", true
+	}
+	return "", false
+}
+
+// ExcludedModules adds the embeddings and lm_head, which transformers never converts, to the listed modules.
+func (q QuantizationConfig) ExcludedModules() []string {
+	modules := []string{"embed_tokens", "lm_head"}
+	modules = append(modules, q.ModulesToNotConvert...)
+	return append(modules, q.LLMInt8SkipModules...)
+}
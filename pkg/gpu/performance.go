@@ -0,0 +1,129 @@
+package gpu
+
+const (
+	bytesPerTB    = 1e12
+	flopsPerTFLOP = 1e12
+)
+
+type InferenceWorkload struct {
+	BatchSize           int
+	PromptLength        int
+	ContextLength       int
+	PrefillWeightBytes  float64
+	DecodeWeightBytes   float64
+	KVBytesPerToken     float64
+	FlopsPerToken       float64
+	AttentionFlopsPerKV float64
+}
+
+type PerformanceEstimate struct {
+	TimeToFirstTokenMs     float64 `json:"ttft_ms"`
+	PrefillBound           string  `json:"prefill_bound"`
+	DecodeStepMs           float64 `json:"decode_step_ms"`
+	DecodeBound            string  `json:"decode_bound"`
+	DecodeTokensPerSec     float64 `json:"decode_tokens_per_sec"`
+	ThroughputTokensPerSec float64 `json:"throughput_tokens_per_sec"`
+	MeetsSLO               *bool   `json:"meets_slo,omitempty"`
+}
+
+// roofline returns the seconds numGPUs devices need for bytes and flops, and which one limits.
+func roofline(gpu GPUSpec, numGPUs int, bytes, flops float64) (float64, string) {
+	memoryTime := bytes / (gpu.Bandwidth * bytesPerTB * float64(numGPUs))
+	computeTime := flops / (gpu.Performance * flopsPerTFLOP * float64(numGPUs))
+	if computeTime > memoryTime {
+		return computeTime, "compute"
+	}
+	return memoryTime, "memory"
+}
+
+// EstimateInference bounds prefill and decode speed from the GPU's peak bandwidth and FLOPs.
+func EstimateInference(gpu GPUSpec, numGPUs int, w InferenceWorkload) PerformanceEstimate {
+	batchF := float64(w.BatchSize)
+	promptF := float64(w.PromptLength)
+	contextF := float64(w.ContextLength)
+
+	prefillTokens := batchF * promptF
+	prefillFlops := prefillTokens*w.FlopsPerToken + batchF*w.AttentionFlopsPerKV*promptF*promptF/2
+	prefillBytes := w.PrefillWeightBytes + prefillTokens*w.KVBytesPerToken
+	prefillTime, prefillBound := roofline(gpu, numGPUs, prefillBytes, prefillFlops)
+
+	decodeBytes := w.DecodeWeightBytes + batchF*contextF*w.KVBytesPerToken
+	decodeFlops := batchF * (w.FlopsPerToken + w.AttentionFlopsPerKV*contextF)
+	decodeTime, decodeBound := roofline(gpu, numGPUs, decodeBytes, decodeFlops)
+
+	return PerformanceEstimate{
+		TimeToFirstTokenMs:     prefillTime * 1000,
+		PrefillBound:           prefillBound,
+		DecodeStepMs:           decodeTime * 1000,
+		DecodeBound:            decodeBound,
+		DecodeTokensPerSec:     1 / decodeTime,
+		ThroughputTokensPerSec: batchF / decodeTime,
+	}
+}
+
+type SpeculativeEstimate struct {
+	ExpectedTokensPerStep float64 `json:"expected_tokens_per_step"`
+	DraftStepMs           float64 `json:"draft_step_ms"`
+	VerifyStepMs          float64 `json:"verify_step_ms"`
+	VerifyBound           string  `json:"verify_bound"`
+	BaselineStepMs        float64 `json:"baseline_step_ms"`
+	BaselineTokensPerSec  float64 `json:"baseline_tokens_per_sec"`
+	DecodeTokensPerSec    float64 `json:"decode_tokens_per_sec"`
+	Speedup               float64 `json:"speedup"`
+}
+
+// EstimateSpeculative compares plain decoding of the target with draft-and-verify cycles that yield expectedTokens each.
+func EstimateSpeculative(gpu GPUSpec, numGPUs int, target, draft InferenceWorkload, verifyWeightBytes float64, lookahead int, expectedTokens float64) SpeculativeEstimate {
+	batchF := float64(target.BatchSize)
+	contextF := float64(target.ContextLength)
+	k := float64(lookahead)
+
+	baseline := EstimateInference(gpu, numGPUs, target)
+	proposal := EstimateInference(gpu, numGPUs, draft)
+	verifyBytes := verifyWeightBytes + batchF*contextF*target.KVBytesPerToken
+	verifyFlops := batchF * (k + 1) * (target.FlopsPerToken + target.AttentionFlopsPerKV*contextF)
+	verifyTime, verifyBound := roofline(gpu, numGPUs, verifyBytes, verifyFlops)
+
+	cycleTime := k*proposal.DecodeStepMs/1000 + verifyTime
+	tokensPerSec := expectedTokens / cycleTime
+	return SpeculativeEstimate{
+		ExpectedTokensPerStep: expectedTokens,
+		DraftStepMs:           proposal.DecodeStepMs,
+		VerifyStepMs:          verifyTime * 1000,
+		VerifyBound:           verifyBound,
+		BaselineStepMs:        baseline.DecodeStepMs,
+		BaselineTokensPerSec:  baseline.DecodeTokensPerSec,
+		DecodeTokensPerSec:    tokensPerSec,
+		Speedup:               tokensPerSec / baseline.DecodeTokensPerSec,
+	}
+}
+
+type LatencySLO struct {
+	MaxTTFTMs          float64
+	MinDecodeTokensSec float64
+}
+
+func (s LatencySLO) Enabled() bool {
+	return s.MaxTTFTMs > 0 || s.MinDecodeTokensSec > 0
+}
+
+func (s LatencySLO) Met(e PerformanceEstimate) bool {
+	if s.MaxTTFTMs > 0 && e.TimeToFirstTokenMs > s.MaxTTFTMs {
+		return false
+	}
+	if s.MinDecodeTokensSec > 0 && e.DecodeTokensPerSec < s.MinDecodeTokensSec {
+		return false
+	}
+	return true
+}
+
+func AttachInferenceEstimates(recommendations []GPURecommendation, w InferenceWorkload, slo LatencySLO) {
+	for i := range recommendations {
+		estimate := EstimateInference(recommendations[i].GPU, recommendations[i].NumGPUs, w)
+		if slo.Enabled() {
+			met := slo.Met(estimate)
+			estimate.MeetsSLO = &met
+		}
+		recommendations[i].Performance = &estimate
+	}
+}
@@ -0,0 +1,67 @@
+package gpu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateInferenceDecode(t *testing.T) {
+	h100 := GPUSpec{Name: "H100", Bandwidth: 3.35, Performance: 989}
+	// Llama-3-70B in bf16: 70,553,706,496 weights and 327,680 KV bytes per
+	// token (2 × 80 layers × 8 KV heads × 128 × 2 bytes).
+	w := InferenceWorkload{
+		BatchSize:           1,
+		PromptLength:        8192,
+		ContextLength:       8192,
+		PrefillWeightBytes:  2 * 70553706496,
+		DecodeWeightBytes:   2 * 70553706496,
+		KVBytesPerToken:     327680,
+		FlopsPerToken:       2 * (70553706496 - 1050673152),
+		AttentionFlopsPerKV: 4 * 80 * 64 * 128,
+	}
+	tests := []struct {
+		name    string
+		numGPUs int
+		stepMs  float64
+	}{
+		// 143,791,767,552 bytes over 3.35 TB/s.
+		{"TP=1", 1, 42.9229},
+		// The same bytes over 8 × 3.35 TB/s.
+		{"TP=8", 8, 5.3654},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := EstimateInference(h100, tt.numGPUs, w)
+			if math.Abs(e.DecodeStepMs-tt.stepMs) > 1e-3 {
+				t.Errorf("decode step = %.4f ms, want %.4f ms", e.DecodeStepMs, tt.stepMs)
+			}
+			if e.DecodeBound != "memory" {
+				t.Errorf("decode bound = %s, want memory", e.DecodeBound)
+			}
+			if e.PrefillBound != "compute" {
+				t.Errorf("prefill bound = %s, want compute", e.PrefillBound)
+			}
+		})
+	}
+}
+
+func TestLatencySLOMet(t *testing.T) {
+	e := PerformanceEstimate{TimeToFirstTokenMs: 200, DecodeTokensPerSec: 40}
+	tests := []struct {
+		name string
+		slo  LatencySLO
+		want bool
+	}{
+		{"TTFT within target", LatencySLO{MaxTTFTMs: 250}, true},
+		{"TTFT too slow", LatencySLO{MaxTTFTMs: 150}, false},
+		{"decode fast enough", LatencySLO{MinDecodeTokensSec: 30}, true},
+		{"decode too slow", LatencySLO{MaxTTFTMs: 250, MinDecodeTokensSec: 50}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.slo.Met(e); got != tt.want {
+				t.Errorf("Met = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
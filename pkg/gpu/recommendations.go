@@ -5,170 +5,183 @@ import (
 	"sort"
 )
 
+// GPUSpec describes one GPU model; HostMemoryPerGPU is a typical node's host DRAM per GPU.
 type GPUSpec struct {
-	Name        string  `json:"name"`
-	Memory      int     `json:"memory_gb"`
-	Bandwidth   float64 `json:"bandwidth_tbs"`
-	Price       float64 `json:"price_usd"`
-	Performance float64 `json:"performance_tflops"`
+	Name             string  `json:"name"`
+	Memory           int     `json:"memory_gb"`
+	HostMemoryPerGPU int     `json:"host_memory_per_gpu_gb"`
+	Bandwidth        float64 `json:"bandwidth_tbs"`
+	Price            float64 `json:"price_usd"`
+	Performance      float64 `json:"performance_tflops"`
 }
 
 var GPUDatabase = []GPUSpec{
 	{
-		Name:        "NVIDIA A100-80GB",
-		Memory:      80,
-		Bandwidth:   2.0,
-		Price:       10000,
-		Performance: 312,
+		Name:             "NVIDIA A100-80GB",
+		Memory:           80,
+		HostMemoryPerGPU: 256,
+		Bandwidth:        2.0,
+		Price:            10000,
+		Performance:      312,
 	},
 	{
-		Name:        "NVIDIA A100-40GB",
-		Memory:      40,
-		Bandwidth:   1.6,
-		Price:       6000,
-		Performance: 312,
+		Name:             "NVIDIA A100-40GB",
+		Memory:           40,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        1.6,
+		Price:            6000,
+		Performance:      312,
 	},
 	{
-		Name:        "NVIDIA A6000",
-		Memory:      48,
-		Bandwidth:   0.768,
-		Price:       4000,
-		Performance: 309.7,
+		Name:             "NVIDIA A6000",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.768,
+		Price:            4000,
+		Performance:      309.7,
 	},
 	{
-		Name:        "NVIDIA L40",
-		Memory:      48,
-		Bandwidth:   0.864,
-		Price:       5000,
-		Performance: 181.6,
+		Name:             "NVIDIA L40",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.864,
+		Price:            5000,
+		Performance:      181.6,
 	},
 	{
-		Name:        "NVIDIA A40",
-		Memory:      48,
-		Bandwidth:   0.696,
-		Price:       3500,
-		Performance: 149.8,
+		Name:             "NVIDIA A40",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.696,
+		Price:            3500,
+		Performance:      149.8,
 	},
 	{
-		Name:        "NVIDIA A30",
-		Memory:      24,
-		Bandwidth:   0.933,
-		Price:       2000,
-		Performance: 165,
+		Name:             "NVIDIA A30",
+		Memory:           24,
+		HostMemoryPerGPU: 64,
+		Bandwidth:        0.933,
+		Price:            2000,
+		Performance:      165,
 	},
 	{
-		Name:        "NVIDIA A10",
-		Memory:      24,
-		Bandwidth:   0.600,
-		Price:       1500,
-		Performance: 125,
+		Name:             "NVIDIA A10",
+		Memory:           24,
+		HostMemoryPerGPU: 64,
+		Bandwidth:        0.600,
+		Price:            1500,
+		Performance:      125,
 	},
 	{
-		Name:        "NVIDIA H100-80GB",
-		Memory:      80,
-		Bandwidth:   3.35,
-		Price:       30000,
-		Performance: 700,
+		Name:             "NVIDIA H100-80GB",
+		Memory:           80,
+		HostMemoryPerGPU: 256,
+		Bandwidth:        3.35,
+		Price:            30000,
+		Performance:      700,
 	},
 	{
-		Name:        "NVIDIA H100-94GB",
-		Memory:      94,
-		Bandwidth:   3.9,
-		Price:       35000,
-		Performance: 830,
+		Name:             "NVIDIA H100-94GB",
+		Memory:           94,
+		HostMemoryPerGPU: 256,
+		Bandwidth:        3.9,
+		Price:            35000,
+		Performance:      830,
 	},
 	{
-		Name:        "NVIDIA A100-80GB",
-		Memory:      80,
-		Bandwidth:   2.0,
-		Price:       10000,
-		Performance: 312,
+		Name:             "NVIDIA A100-80GB",
+		Memory:           80,
+		HostMemoryPerGPU: 256,
+		Bandwidth:        2.0,
+		Price:            10000,
+		Performance:      312,
 	},
 	{
-		Name:        "NVIDIA A100-40GB",
-		Memory:      40,
-		Bandwidth:   1.6,
-		Price:       6000,
-		Performance: 312,
+		Name:             "NVIDIA A100-40GB",
+		Memory:           40,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        1.6,
+		Price:            6000,
+		Performance:      312,
 	},
 	{
-		Name:        "NVIDIA A6000",
-		Memory:      48,
-		Bandwidth:   0.768,
-		Price:       4000,
-		Performance: 309.7,
+		Name:             "NVIDIA A6000",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.768,
+		Price:            4000,
+		Performance:      309.7,
 	},
 	{
-		Name:        "NVIDIA L40",
-		Memory:      48,
-		Bandwidth:   0.864,
-		Price:       5000,
-		Performance: 181.6,
+		Name:             "NVIDIA L40",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.864,
+		Price:            5000,
+		Performance:      181.6,
 	},
 	{
-		Name:        "NVIDIA RTX 6000 Ada Generation",
-		Memory:      48,
-		Bandwidth:   0.960,
-		Price:       6800,
-		Performance: 260,
+		Name:             "NVIDIA RTX 6000 Ada Generation",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.960,
+		Price:            6800,
+		Performance:      260,
 	},
 	{
-		Name:        "NVIDIA A40",
-		Memory:      48,
-		Bandwidth:   0.696,
-		Price:       3500,
-		Performance: 149.8,
+		Name:             "NVIDIA A40",
+		Memory:           48,
+		HostMemoryPerGPU: 128,
+		Bandwidth:        0.696,
+		Price:            3500,
+		Performance:      149.8,
 	},
 	{
-		Name:        "NVIDIA A30",
-		Memory:      24,
-		Bandwidth:   0.933,
-		Price:       2000,
-		Performance: 165,
+		Name:             "NVIDIA A30",
+		Memory:           24,
+		HostMemoryPerGPU: 64,
+		Bandwidth:        0.933,
+		Price:            2000,
+		Performance:      165,
 	},
 	{
-		Name:        "NVIDIA A10",
-		Memory:      24,
-		Bandwidth:   0.600,
-		Price:       1500,
-		Performance: 125,
+		Name:             "NVIDIA A10",
+		Memory:           24,
+		HostMemoryPerGPU: 64,
+		Bandwidth:        0.600,
+		Price:            1500,
+		Performance:      125,
 	},
 }
 
 type GPURecommendation struct {
-	GPU              GPUSpec `json:"gpu"`
-	NumGPUs          int     `json:"num_gpus"`
-	UtilizationScore float64 `json:"utilization_score"`
-	CostScore        float64 `json:"cost_score"`
-	TotalCost        float64 `json:"total_cost"`
+	GPU              GPUSpec              `json:"gpu"`
+	NumGPUs          int                  `json:"num_gpus"`
+	UtilizationScore float64              `json:"utilization_score"`
+	CostScore        float64              `json:"cost_score"`
+	TotalCost        float64              `json:"total_cost"`
+	Performance      *PerformanceEstimate `json:"performance,omitempty"`
 }
 
-func GetGPURecommendations(totalMemoryGB float64, isTraining bool) []GPURecommendation {
-	var recommendations []GPURecommendation
-
-	for _, gpu := range GPUDatabase {
-		numGPUs := int(math.Ceil(totalMemoryGB / float64(gpu.Memory)))
-		if numGPUs < 1 {
-			numGPUs = 1
-		}
-		memoryUtilization := totalMemoryGB / (float64(numGPUs) * float64(gpu.Memory))
-		utilizationScore := memoryUtilization * 100
-		totalCost := float64(numGPUs) * gpu.Price
-		costScore := totalCost / gpu.Performance
-		if isTraining {
-			utilizationScore *= (gpu.Bandwidth / 2.0)
-			costScore *= 0.8
-		}
-
-		rec := GPURecommendation{
-			GPU:              gpu,
-			NumGPUs:          numGPUs,
-			UtilizationScore: utilizationScore,
-			CostScore:        costScore,
-			TotalCost:        totalCost,
-		}
-		recommendations = append(recommendations, rec)
+func newRecommendation(gpu GPUSpec, numGPUs int, totalMemoryGB float64, isTraining bool) GPURecommendation {
+	memoryUtilization := totalMemoryGB / (float64(numGPUs) * float64(gpu.Memory))
+	utilizationScore := memoryUtilization * 100
+	totalCost := float64(numGPUs) * gpu.Price
+	costScore := totalCost / gpu.Performance
+	if isTraining {
+		utilizationScore *= (gpu.Bandwidth / 2.0)
+		costScore *= 0.8
+	}
+	return GPURecommendation{
+		GPU:              gpu,
+		NumGPUs:          numGPUs,
+		UtilizationScore: utilizationScore,
+		CostScore:        costScore,
+		TotalCost:        totalCost,
 	}
+}
+
+func rankRecommendations(recommendations []GPURecommendation) []GPURecommendation {
 	sort.Slice(recommendations, func(i, j int) bool {
 		scoreI := recommendations[i].UtilizationScore - recommendations[i].CostScore
 		scoreJ := recommendations[j].UtilizationScore - recommendations[j].CostScore
@@ -181,3 +194,74 @@ func GetGPURecommendations(totalMemoryGB float64, isTraining bool) []GPURecommen
 
 	return recommendations
 }
+
+// DeviceOverhead is the memory every GPU loses outside the model: FixedGB
+// for the CUDA context and framework workspaces, and Fragmentation of what
+// the model allocates on it.
+type DeviceOverhead struct {
+	FixedGB       float64
+	Fragmentation float64
+}
+
+// GPUsFor counts the devices that hold totalMemoryGB of model memory, or 0
+// when the overhead alone fills the device.
+func (o DeviceOverhead) GPUsFor(device GPUSpec, totalMemoryGB float64) int {
+	usableGB := float64(device.Memory) - o.FixedGB
+	if usableGB <= 0 {
+		return 0
+	}
+	numGPUs := int(math.Ceil(totalMemoryGB * (1 + o.Fragmentation) / usableGB))
+	if numGPUs < 1 {
+		numGPUs = 1
+	}
+	return numGPUs
+}
+
+// GetGPURecommendations counts the GPUs of each type that hold totalMemoryGB
+// of model memory, excluding framework overhead, once every device has paid
+// its own overhead.
+func GetGPURecommendations(totalMemoryGB float64, isTraining bool, overhead DeviceOverhead) []GPURecommendation {
+	var recommendations []GPURecommendation
+
+	allocatedGB := totalMemoryGB * (1 + overhead.Fragmentation)
+	for _, gpu := range GPUDatabase {
+		numGPUs := overhead.GPUsFor(gpu, totalMemoryGB)
+		if numGPUs == 0 {
+			continue
+		}
+		recommendations = append(recommendations, newRecommendation(gpu, numGPUs, allocatedGB+overhead.FixedGB*float64(numGPUs), isTraining))
+	}
+	return rankRecommendations(recommendations)
+}
+
+// GetPerDeviceRecommendations keeps the GPUs that fit a fixed per-device share.
+func GetPerDeviceRecommendations(perGPUMemoryGB float64, numGPUs int, isTraining bool) []GPURecommendation {
+	var recommendations []GPURecommendation
+
+	for _, gpu := range GPUDatabase {
+		if perGPUMemoryGB > float64(gpu.Memory) {
+			continue
+		}
+		totalMemoryGB := perGPUMemoryGB * float64(numGPUs)
+		recommendations = append(recommendations, newRecommendation(gpu, numGPUs, totalMemoryGB, isTraining))
+	}
+	return rankRecommendations(recommendations)
+}
+
+// GetOffloadRecommendations keeps the GPUs that fit the per-device share and whose node has hostMemoryGB of host RAM per GPU, or hostLimitGB when set.
+func GetOffloadRecommendations(perGPUMemoryGB, hostMemoryGB float64, numGPUs int, hostLimitGB float64) []GPURecommendation {
+	var recommendations []GPURecommendation
+
+	for _, gpu := range GPUDatabase {
+		hostLimit := float64(gpu.HostMemoryPerGPU)
+		if hostLimitGB > 0 {
+			hostLimit = hostLimitGB
+		}
+		if perGPUMemoryGB > float64(gpu.Memory) || hostMemoryGB > hostLimit {
+			continue
+		}
+		totalMemoryGB := perGPUMemoryGB * float64(numGPUs)
+		recommendations = append(recommendations, newRecommendation(gpu, numGPUs, totalMemoryGB, true))
+	}
+	return rankRecommendations(recommendations)
+}
@@ -0,0 +1,59 @@
+package gpu
+
+const (
+	DefaultMFU    = 0.4
+	secondsPerDay = 86400.0
+)
+
+type TrainingEstimate struct {
+	GPU           string  `json:"gpu"`
+	NumGPUs       int     `json:"num_gpus"`
+	MFU           float64 `json:"mfu"`
+	Tokens        float64 `json:"tokens"`
+	TotalFlops    float64 `json:"total_flops"`
+	WallClockDays float64 `json:"wall_clock_days"`
+	GPUHours      float64 `json:"gpu_hours"`
+	HourlyRate    float64 `json:"hourly_rate_usd,omitempty"`
+	RentalCost    float64 `json:"rental_cost_usd,omitempty"`
+	PurchaseCost  float64 `json:"purchase_cost_usd"`
+}
+
+func FindGPU(name string) (GPUSpec, bool) {
+	for _, gpu := range GPUDatabase {
+		if gpu.Name == name {
+			return gpu, true
+		}
+	}
+	return GPUSpec{}, false
+}
+
+func GPUNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, gpu := range GPUDatabase {
+		if !seen[gpu.Name] {
+			seen[gpu.Name] = true
+			names = append(names, gpu.Name)
+		}
+	}
+	return names
+}
+
+// EstimateTraining converts a FLOP budget into wall-clock time and cost at the given MFU.
+func EstimateTraining(gpu GPUSpec, numGPUs int, tokens, totalFlops, mfu, hourlyRate float64) TrainingEstimate {
+	clusterFlops := gpu.Performance * flopsPerTFLOP * float64(numGPUs) * mfu
+	seconds := totalFlops / clusterFlops
+	gpuHours := seconds / 3600 * float64(numGPUs)
+	return TrainingEstimate{
+		GPU:           gpu.Name,
+		NumGPUs:       numGPUs,
+		MFU:           mfu,
+		Tokens:        tokens,
+		TotalFlops:    totalFlops,
+		WallClockDays: seconds / secondsPerDay,
+		GPUHours:      gpuHours,
+		HourlyRate:    hourlyRate,
+		RentalCost:    gpuHours * hourlyRate,
+		PurchaseCost:  gpu.Price * float64(numGPUs),
+	}
+}
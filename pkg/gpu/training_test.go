@@ -0,0 +1,41 @@
+package gpu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateTraining(t *testing.T) {
+	h100 := GPUSpec{Name: "NVIDIA H100-80GB", Price: 30000, Performance: 700}
+	tests := []struct {
+		name     string
+		device   GPUSpec
+		numGPUs  int
+		flops    float64
+		mfu      float64
+		days     float64
+		gpuHours float64
+	}{
+		// 1.8e17 FLOPs at 8 × 100 TFLOPs × 50% take 450 seconds.
+		{"small run", GPUSpec{Performance: 100}, 8, 1.8e17, 0.5, 450.0 / 86400, 1},
+		// 1e24 FLOPs at 1024 × 700 TFLOPs × 40% take 3,487,723 seconds.
+		{"1024 H100s", h100, 1024, 1e24, 0.4, 40.3672, 992063.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := EstimateTraining(tt.device, tt.numGPUs, 1e9, tt.flops, tt.mfu, 2)
+			if math.Abs(e.WallClockDays-tt.days)/tt.days > 1e-5 {
+				t.Errorf("wall clock = %.4f days, want %.4f", e.WallClockDays, tt.days)
+			}
+			if math.Abs(e.GPUHours-tt.gpuHours)/tt.gpuHours > 1e-5 {
+				t.Errorf("GPU-hours = %.1f, want %.1f", e.GPUHours, tt.gpuHours)
+			}
+			if e.RentalCost != 2*e.GPUHours {
+				t.Errorf("rental cost = %.2f, want %.2f", e.RentalCost, 2*e.GPUHours)
+			}
+			if want := tt.device.Price * float64(tt.numGPUs); e.PurchaseCost != want {
+				t.Errorf("purchase cost = %.0f, want %.0f", e.PurchaseCost, want)
+			}
+		})
+	}
+}
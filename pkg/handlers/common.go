@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"compute-gauge/pkg/calc"
 	"compute-gauge/pkg/config"
+	"compute-gauge/pkg/gpu"
 	"compute-gauge/pkg/memory"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 func getProjectDir() string {
@@ -27,6 +31,16 @@ func getProjectDir() string {
 	log.Printf("Project directory: %s", absPath)
 	return absPath
 }
+
+// errorStatus reports request validation failures as 400 and anything else as 500.
+func errorStatus(err error) int {
+	var invalid *memory.ValidationError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func HandleIndex(w http.ResponseWriter, r *http.Request) {
 	models, err := config.LoadModelConfigs()
 	if err != nil {
@@ -43,13 +57,19 @@ func HandleIndex(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	dataTypes := make([]string, 0, len(config.DataTypeSizes))
-	for dtype := range config.DataTypeSizes {
-		dataTypes = append(dataTypes, dtype)
+	kvCacheDtypes := make([]string, 0, len(calc.KVCacheFormats))
+	for dtype := range calc.KVCacheFormats {
+		kvCacheDtypes = append(kvCacheDtypes, dtype)
 	}
+	sort.Strings(kvCacheDtypes)
 	data := memory.PageData{
-		Models:    models,
-		DataTypes: dataTypes,
+		Models:        models,
+		DataTypes:     config.QuantSchemeNames(),
+		StateTypes:    config.PlainDtypeNames(),
+		KVCacheDtypes: kvCacheDtypes,
+		Optimizers:    calc.OptimizerNames(),
+		Frameworks:    calc.FrameworkNames(),
+		GPUs:          gpu.GPUNames(),
 	}
 	w.Header().Set("Content-Type", "text/html")
 	if err := tmpl.Execute(w, data); err != nil {
@@ -71,7 +91,133 @@ func HandleCalculate(w http.ResponseWriter, r *http.Request) {
 	result, err := memory.CalculateMemoryRequirements(&req)
 	if err != nil {
 		log.Printf("Error calculating memory: %v", err)
-		http.Error(w, fmt.Sprintf("Error calculating memory: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error calculating memory: %v", err), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func HandleParallelism(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memory.ParallelismRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid request format: %v", err), http.StatusBadRequest)
+		return
+	}
+	result, err := memory.PlanParallelism(&req)
+	if err != nil {
+		log.Printf("Error planning parallelism: %v", err)
+		http.Error(w, fmt.Sprintf("Error planning parallelism: %v", err), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func HandleCapacity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memory.CapacityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid request format: %v", err), http.StatusBadRequest)
+		return
+	}
+	result, err := memory.SolveCapacity(&req)
+	if err != nil {
+		log.Printf("Error solving capacity: %v", err)
+		http.Error(w, fmt.Sprintf("Error solving capacity: %v", err), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func HandleServing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memory.ServingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid request format: %v", err), http.StatusBadRequest)
+		return
+	}
+	result, err := memory.EstimateServingCapacity(&req)
+	if err != nil {
+		log.Printf("Error estimating serving capacity: %v", err)
+		http.Error(w, fmt.Sprintf("Error estimating serving capacity: %v", err), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func HandleAlignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memory.AlignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid request format: %v", err), http.StatusBadRequest)
+		return
+	}
+	result, err := memory.PlanAlignment(&req)
+	if err != nil {
+		log.Printf("Error planning alignment run: %v", err)
+		http.Error(w, fmt.Sprintf("Error planning alignment run: %v", err), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func HandleSpeculative(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memory.SpeculativeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid request format: %v", err), http.StatusBadRequest)
+		return
+	}
+	models, err := config.LoadModelConfigs()
+	if err != nil {
+		log.Printf("Error loading models: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	result, err := memory.EstimateSpeculativeDecoding(&req, models)
+	if err != nil {
+		log.Printf("Error estimating speculative decoding: %v", err)
+		http.Error(w, fmt.Sprintf("Error estimating speculative decoding: %v", err), errorStatus(err))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/gpu"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlignmentPPO = "ppo"
+	AlignmentDPO = "dpo"
+
+	RolePolicy    = "policy"
+	RoleReference = "reference"
+	RoleReward    = "reward"
+	RoleValue     = "value"
+)
+
+// alignmentRoles lists the models each method holds and whether each trains by default.
+var alignmentRoles = map[string][]struct {
+	role      string
+	trainable bool
+}{
+	AlignmentPPO: {{RolePolicy, true}, {RoleReference, false}, {RoleReward, false}, {RoleValue, true}},
+	AlignmentDPO: {{RolePolicy, true}, {RoleReference, false}},
+}
+
+// PlanAlignment sizes every model an RLHF or preference-tuning run keeps on one GPU.
+func PlanAlignment(req *AlignmentRequest) (*AlignmentResponse, error) {
+	r := *req
+	if err := validateAlignment(&r); err != nil {
+		return nil, &ValidationError{err}
+	}
+
+	resp := AlignmentResponse{
+		Method:         r.Method,
+		BatchSize:      r.BatchSize,
+		SequenceLength: r.PromptLength + r.GenerationLength,
+	}
+	if r.Method == AlignmentDPO {
+		resp.BatchSize *= 2
+	}
+	largest := 0.0
+	colocated := 0.0
+	for _, spec := range alignmentRoles[r.Method] {
+		role := r.role(spec.role)
+		trainable := spec.trainable
+		if role.Trainable != nil {
+			trainable = *role.Trainable
+		}
+		footprint, err := r.roleFootprint(role, trainable, resp.BatchSize, resp.SequenceLength)
+		if err != nil {
+			return nil, fmt.Errorf("%s model: %w", spec.role, err)
+		}
+		resp.Roles = append(resp.Roles, footprint)
+		if trainable && role.ShardingStrategy != "" {
+			resp.DataParallelSize = role.DataParallelSize
+		}
+		// Frozen roles are replicated on every rank; sharded roles count their per-GPU share.
+		colocated += footprint.TotalBytes
+		if footprint.TotalBytes > largest {
+			largest = footprint.TotalBytes
+		}
+	}
+	policy := r.role(RolePolicy).MemoryRequest
+	if policy.Framework == "" {
+		policy.Framework = calc.FrameworkPyTorch
+	}
+	resp.ColocatedMemory = calc.FormatMemory(colocated)
+	resp.LargestRoleMemory = calc.FormatMemory(largest)
+	if resp.DataParallelSize > 1 {
+		overhead := policy.frameworkOverhead().PerGPU(colocated)
+		resp.FrameworkOverhead = calc.FormatMemory(overhead)
+		resp.ColocatedGPUs = gpu.GetPerDeviceRecommendations((colocated+overhead)/(1024*1024*1024), resp.DataParallelSize, true)
+	} else {
+		resp.ColocatedGPUs = gpu.GetGPURecommendations(colocated/(1024*1024*1024), true, policy.deviceOverhead())
+		resp.FrameworkOverhead = calc.FormatMemory(policy.deviceFrameworkOverhead(colocated, resp.ColocatedGPUs))
+	}
+	if len(resp.ColocatedGPUs) > 3 {
+		resp.ColocatedGPUs = resp.ColocatedGPUs[:3]
+	}
+	return &resp, nil
+}
+
+func (r *AlignmentRequest) role(name string) AlignmentRole {
+	for _, role := range r.Roles {
+		if role.Role == name {
+			return role
+		}
+	}
+	return AlignmentRole{}
+}
+
+// roleFootprint sizes one role on one GPU, keeping a KV cache only for the role that generates.
+func (r *AlignmentRequest) roleFootprint(role AlignmentRole, trainable bool, batchSize, seqLength int) (RoleFootprint, error) {
+	req := role.MemoryRequest
+	req.BatchSize = batchSize
+	req.SequenceLength = seqLength
+	req.PromptLength = r.PromptLength
+	req.TrainingTokens = 0
+	if !trainable {
+		req.Optimizer = ""
+	}
+	generates := r.Method == AlignmentPPO && role.Role == RolePolicy
+	req.scalarHead = role.Role == RoleReward || role.Role == RoleValue
+	result, fp, err := calculate(&req)
+	if err != nil {
+		return RoleFootprint{}, err
+	}
+
+	footprint := RoleFootprint{
+		Role:      role.Role,
+		Precision: req.TorchDtype,
+		Trainable: trainable,
+		Generates: generates,
+		Params:    result.TotalParams,
+	}
+	total, weights, activations := fp.Inference.Total(), fp.Inference.Weights, fp.Inference.Activations
+	cache := fp.Inference.KVCacheGPU + fp.Inference.SSMState + fp.Inference.ConvState
+	if trainable {
+		total, weights, activations = fp.Training.Total(), fp.Training.Weights(), fp.Training.Activations
+		cache = fp.Training.KVCache + fp.Training.SSMState + fp.Training.ConvState
+		trainingState := fp.Training.State()
+		if sharded := fp.Sharded; sharded.PerGPU > 0 {
+			// The sharded kv_cache already holds the SSM and conv state.
+			total, weights, activations = sharded.PerGPU-sharded.Framework, sharded.Weights, sharded.Activations
+			cache = sharded.KVCache
+			trainingState = sharded.OptimizerStates + sharded.Gradients + sharded.MasterWeights
+		}
+		footprint.TrainingState = calc.FormatMemory(trainingState)
+	}
+	footprint.Weights = calc.FormatMemory(weights)
+	footprint.Activations = calc.FormatMemory(activations)
+	if generates {
+		footprint.GenerationCache = calc.FormatMemory(cache)
+	} else {
+		total -= cache
+	}
+	footprint.TotalBytes = total
+	footprint.Total = calc.FormatMemory(total)
+	return footprint, nil
+}
+
+func validateAlignment(r *AlignmentRequest) error {
+	if r.Method == "" {
+		r.Method = AlignmentPPO
+	}
+	roles, ok := alignmentRoles[r.Method]
+	if !ok {
+		return fmt.Errorf("invalid alignment method: %s", r.Method)
+	}
+	if r.BatchSize <= 0 || r.PromptLength <= 0 {
+		return fmt.Errorf("batch size and prompt length must be positive")
+	}
+	if r.GenerationLength < 0 {
+		return fmt.Errorf("generation length must not be negative")
+	}
+	if r.Method == AlignmentPPO && r.GenerationLength == 0 {
+		return fmt.Errorf("ppo requires a positive generation length")
+	}
+	expected := make(map[string]bool)
+	var names []string
+	for _, spec := range roles {
+		expected[spec.role] = true
+		names = append(names, spec.role)
+	}
+	seen := make(map[string]bool)
+	dataParallel := 0
+	for _, role := range r.Roles {
+		if !expected[role.Role] {
+			return fmt.Errorf("invalid %s role %q, valid roles: %s", r.Method, role.Role, strings.Join(names, ", "))
+		}
+		if seen[role.Role] {
+			return fmt.Errorf("duplicate %s role", role.Role)
+		}
+		seen[role.Role] = true
+	}
+	for _, spec := range roles {
+		if !seen[spec.role] {
+			return fmt.Errorf("%s requires a %s model", r.Method, spec.role)
+		}
+		role := r.role(spec.role)
+		trainable := spec.trainable
+		if role.Trainable != nil {
+			trainable = *role.Trainable
+		}
+		if trainable && role.Optimizer == "" {
+			return fmt.Errorf("trainable %s model requires an optimizer", spec.role)
+		}
+		if trainable && role.ShardingStrategy != "" {
+			if dataParallel != 0 && role.DataParallelSize != dataParallel {
+				return fmt.Errorf("sharded roles share their GPUs, so they need the same data parallel size")
+			}
+			dataParallel = role.DataParallelSize
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"testing"
+)
+
+func ppoRequest(strategy string, dataParallel int) *AlignmentRequest {
+	model := llama2_7BCapacityRequest("").MemoryRequest
+	model.TorchDtype = "bfloat16"
+	trained := model
+	trained.Optimizer = "AdamW"
+	trained.ShardingStrategy = strategy
+	trained.DataParallelSize = dataParallel
+	return &AlignmentRequest{
+		Method:           AlignmentPPO,
+		BatchSize:        8,
+		PromptLength:     512,
+		GenerationLength: 512,
+		Roles: []AlignmentRole{
+			{Role: RolePolicy, MemoryRequest: trained},
+			{Role: RoleReference, MemoryRequest: model},
+			{Role: RoleReward, MemoryRequest: model},
+			{Role: RoleValue, MemoryRequest: trained},
+		},
+	}
+}
+
+func TestAlignmentShardedRoles(t *testing.T) {
+	unsharded, err := PlanAlignment(ppoRequest("", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharded, err := PlanAlignment(ppoRequest(calc.ShardZeRO3, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, role := range sharded.Roles {
+		before := unsharded.Roles[i]
+		if !role.Trainable {
+			if role.TotalBytes != before.TotalBytes {
+				t.Errorf("frozen %s changed from %s to %s", role.Role, before.Total, role.Total)
+			}
+			continue
+		}
+		if role.TotalBytes >= before.TotalBytes {
+			t.Errorf("%s with zero3 = %s, want less than unsharded %s", role.Role, role.Total, before.Total)
+		}
+	}
+
+	// The sharded value model is its per-GPU share less the KV cache it never uses.
+	value := ppoRequest(calc.ShardZeRO3, 8).role(RoleValue).MemoryRequest
+	value.BatchSize, value.SequenceLength, value.PromptLength = 8, 1024, 512
+	value.scalarHead = true
+	_, fp, err := calculate(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Role totals leave out the framework overhead, which each GPU pays once.
+	want, cache := fp.Sharded.PerGPU, fp.Sharded.KVCache+fp.Sharded.Framework
+	if got := sharded.Roles[3].TotalBytes; got != want-cache {
+		t.Errorf("sharded value model = %s, want %s", calc.FormatMemory(got), calc.FormatMemory(want-cache))
+	}
+}
+
+func TestAlignmentColocatedPerDevice(t *testing.T) {
+	tests := []struct {
+		name         string
+		batch        int
+		perDevice    string
+		numGPUs      int
+		wantFeasible bool
+	}{
+		// Policy 55.61 + reference 13.62 + reward 13.37 + value 49.38 GB on each of 8 ranks,
+		// plus PyTorch's 1.5 GB and 5% each: no single GPU holds it, however many ranks share the run.
+		{"batch 8", 8, "131.98 GB", 8, false},
+		// One sequence per rank: 20.63 + 12.68 + 12.44 + 19.61 GB plus overhead fits an 80 GB GPU.
+		{"batch 1", 1, "65.36 GB", 8, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ppoRequest(calc.ShardZeRO3, 8)
+			r.BatchSize = tt.batch
+			resp, err := PlanAlignment(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum := 0.0
+			for _, role := range resp.Roles {
+				sum += role.TotalBytes
+			}
+			if resp.ColocatedMemory != tt.perDevice || resp.ColocatedMemory != calc.FormatMemory(sum) {
+				t.Errorf("colocated = %s, want %s (sum of roles %s)", resp.ColocatedMemory, tt.perDevice, calc.FormatMemory(sum))
+			}
+			overhead := calc.FrameworkOverheads[calc.FrameworkPyTorch].PerGPU(sum)
+			if resp.FrameworkOverhead != calc.FormatMemory(overhead) {
+				t.Errorf("framework overhead = %s, want %s per GPU", resp.FrameworkOverhead, calc.FormatMemory(overhead))
+			}
+			if resp.DataParallelSize != tt.numGPUs {
+				t.Errorf("data parallel size = %d, want %d", resp.DataParallelSize, tt.numGPUs)
+			}
+			if got := len(resp.ColocatedGPUs) > 0; got != tt.wantFeasible {
+				t.Fatalf("recommended %d GPUs, want feasible %v", len(resp.ColocatedGPUs), tt.wantFeasible)
+			}
+			for _, rec := range resp.ColocatedGPUs {
+				if rec.NumGPUs != tt.numGPUs || float64(rec.GPU.Memory) < (sum+overhead)/(1024*1024*1024) {
+					t.Errorf("recommended %d × %s for %s per GPU", rec.NumGPUs, rec.GPU.Name, resp.ColocatedMemory)
+				}
+			}
+		})
+	}
+}
+
+func TestAlignmentScalarHeads(t *testing.T) {
+	resp, err := PlanAlignment(ppoRequest("", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, reference, reward, value := resp.Roles[0], resp.Roles[1], resp.Roles[2], resp.Roles[3]
+	// The 32000 × 4096 lm_head becomes a 4096-wide score head.
+	wantParams := policy.Params - 32000*4096 + 4096
+	for _, role := range []RoleFootprint{reward, value} {
+		if role.Params != wantParams {
+			t.Errorf("%s params = %.0f, want %.0f", role.Role, role.Params, wantParams)
+		}
+	}
+	if reference.Params != policy.Params {
+		t.Errorf("reference params = %.0f, want the policy's %.0f", reference.Params, policy.Params)
+	}
+
+	tests := []struct {
+		name           string
+		role           RoleFootprint
+		weights, state string
+	}{
+		// 6,607,347,712 bf16 weights.
+		{"reward weights", reward, "12.31 GB", ""},
+		// 16 bytes per parameter: fp32 gradients, master weights and both AdamW moments.
+		{"value training state", value, "12.31 GB", "98.46 GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.role.Weights != tt.weights || tt.role.TrainingState != tt.state {
+				t.Errorf("weights, state = %s, %s; want %s, %s", tt.role.Weights, tt.role.TrainingState, tt.weights, tt.state)
+			}
+		})
+	}
+}
+
+func TestAlignmentRejectsMismatchedDataParallel(t *testing.T) {
+	r := ppoRequest(calc.ShardZeRO3, 8)
+	r.Roles[3].DataParallelSize = 4
+	if _, err := PlanAlignment(r); err == nil {
+		t.Error("expected an error for sharded roles on different data parallel sizes")
+	}
+}
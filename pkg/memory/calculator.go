@@ -2,93 +2,610 @@ package memory
 
 import (
 	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/config"
 	"compute-gauge/pkg/gpu"
 	"fmt"
+	"math"
+	"strings"
 )
 
-func CalculateMemoryRequirements(r *MemoryRequest) (*MemoryResponse, error) {
-	if err := validateRequest(r); err != nil {
-		return nil, err
+// ValidationError marks a request that cannot be calculated as given.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func CalculateMemoryRequirements(req *MemoryRequest) (*MemoryResponse, error) {
+	resp, _, err := calculate(req)
+	return resp, err
+}
+
+// footprint keeps the unformatted figures behind a MemoryResponse for the planners that build on it.
+type footprint struct {
+	Inference calc.InferenceMemory
+	Training  calc.TrainingMemory
+	Sharded   calc.TrainingFootprint
+	Plan      calc.TrainingFootprint
+}
+
+func calculate(req *MemoryRequest) (*MemoryResponse, footprint, error) {
+	var fp footprint
+	r := *req
+	if err := validateRequest(&r); err != nil {
+		return nil, fp, &ValidationError{err}
 	}
+	applyRequestDefaults(&r)
 
 	var resp MemoryResponse
-	inferenceResults := calc.CalculateInferenceMemory(
-		r.ModelSize,
-		r.TorchDtype,
-		r.BatchSize,
-		r.SequenceLength,
-		r.HiddenSize,
-		r.NumHiddenLayers,
-		r.NumAttentionHeads,
-	)
+	spec := r.modelSpec()
+	inference, inferenceActivations := calc.CalculateInferenceMemory(spec, r.TorchDtype, r.BatchSize, r.SequenceLength, r.activationOptions(), r.kvCacheOptions())
+	fp.Inference = inference
+	inferenceResults := inference.Format()
+	resp.ActivationBreakdown = inferenceActivations.Format()
+	if len(inferenceActivations.Removed) > 0 {
+		resp.ActivationSavings = inferenceActivations.FormatRemoved()
+	}
 	resp.ModelWeights = inferenceResults["model_weights"]
 	resp.KVCache = inferenceResults["kv_cache"]
+	resp.KVCacheDtype = r.KVCacheDtype
+	resp.AttentionVariant = config.DetectAttentionVariant(r.NumAttentionHeads, r.NumKeyValueHeads, r.KVLoRARank)
+	resp.KVCacheGPU = inferenceResults["kv_cache_gpu"]
+	resp.KVCacheHost = inferenceResults["kv_cache_host"]
+	if spec.SlidingWindow > 0 {
+		kvBreakdown := calc.GetKVCacheBreakdown(spec, r.BatchSize, r.SequenceLength, r.KVCacheDtype)
+		resp.KVCacheBreakdown = kvBreakdown.Format()
+	}
+	resp.SSMState = inferenceResults["ssm_state"]
+	resp.ConvState = inferenceResults["conv_state"]
+	if len(spec.Components) > 0 {
+		resp.Components = make(map[string]ComponentSummary)
+		for _, c := range spec.Components {
+			resp.Components[c.Name] = ComponentSummary{
+				Params:    c.Params(),
+				Weights:   calc.FormatMemory(c.Weights()),
+				Dtype:     c.Precision,
+				Tokens:    c.Tokens,
+				Trainable: c.Trainable,
+			}
+		}
+		resp.ComponentWeights = inferenceResults["component_weights"]
+		resp.CrossAttentionKV = inferenceResults["cross_attention_kv"]
+		resp.ImageTokens = r.imageTokens()
+	}
+	if spec.Blocks != nil {
+		resp.LayerMix = make(map[string]int)
+		for _, block := range []string{calc.BlockAttention, calc.BlockMamba, calc.BlockMLP, calc.BlockMoE} {
+			if count := spec.CountBlocks(block); count > 0 {
+				resp.LayerMix[block] = count
+			}
+		}
+	}
 	resp.ActivationMemory = inferenceResults["activation_memory"]
+	resp.Framework = r.Framework
 	resp.InferenceMemory = inferenceResults["inference_memory"]
+	resp.ExpertWeights = inferenceResults["expert_weights"]
+	resp.RouterMemory = inferenceResults["router_memory"]
 
-	inferenceMemoryBytes, err := parseMemoryString(resp.InferenceMemory)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing inference memory: %v", err)
-	}
-	inferenceMemoryGB := inferenceMemoryBytes / (1024 * 1024 * 1024)
-	resp.InferenceGPUs = gpu.GetGPURecommendations(inferenceMemoryGB, false)
+	inferenceMemoryGB := inference.Total() / (1024 * 1024 * 1024)
+	resp.InferenceGPUs = gpu.GetGPURecommendations(inferenceMemoryGB, false, r.deviceOverhead())
 	if len(resp.InferenceGPUs) > 3 {
 		resp.InferenceGPUs = resp.InferenceGPUs[:3]
 	}
+	resp.FrameworkOverhead = calc.FormatMemory(r.deviceFrameworkOverhead(inference.Total(), resp.InferenceGPUs))
 
 	if r.Optimizer != "" {
-		trainingResults := calc.CalculateTrainingMemory(
-			r.ModelSize,
-			r.TorchDtype,
-			r.BatchSize,
-			r.SequenceLength,
-			r.HiddenSize,
-			r.NumHiddenLayers,
-			r.NumAttentionHeads,
-			r.Optimizer,
-			r.ModelSize,
-		)
+		opts := r.trainingOptions()
+		training, trainingActivations, perGPU := calc.CalculateTrainingMemory(spec, r.TorchDtype, r.BatchSize, r.SequenceLength, opts)
+		fp.Training, fp.Sharded = training, perGPU
+		trainingResults := training.Format()
+		resp.TrainingActivationMemory = trainingResults["activation_memory"]
+		resp.TrainingActivationBreakdown = trainingActivations.Format()
+		if len(trainingActivations.Removed) > 0 {
+			resp.ActivationSavings = trainingActivations.FormatRemoved()
+		}
+		resp.FineTuneMethod = opts.Method
+		resp.TrainableParams = opts.TrainableParams(spec)
+		resp.BaseWeights = trainingResults["base_weights"]
+		resp.AdapterWeights = trainingResults["adapter_weights"]
+		policy := opts.Policy.WithDefaults(r.TorchDtype)
+		resp.PrecisionPolicy = &policy
+		resp.BytesPerParam = policy.BytesPerParam(r.Optimizer, opts.FactoredStateFraction(spec))
+		resp.OptimizerPaged = calc.Optimizers[r.Optimizer].Paged
+		resp.MasterWeights = trainingResults["master_weights"]
 		resp.OptimizerMemory = trainingResults["optimizer_memory"]
 		resp.GradientsMemory = trainingResults["gradients_memory"]
 		resp.TrainingMemory = trainingResults["training_memory"]
-		trainingMemoryBytes, err := parseMemoryString(resp.TrainingMemory)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing training memory: %v", err)
+		trainingMemoryGB := training.Total() / (1024 * 1024 * 1024)
+		resp.TrainingGPUs = gpu.GetGPURecommendations(trainingMemoryGB, true, r.deviceOverhead())
+		resp.TrainingFrameworkOverhead = calc.FormatMemory(r.deviceFrameworkOverhead(training.Total(), resp.TrainingGPUs))
+		if opts.Sharding.Enabled() || opts.Offload.Enabled() {
+			resp.ShardingStrategy = r.ShardingStrategy
+			resp.DataParallelSize = r.DataParallelSize
+			resp.TrainingFrameworkOverhead = calc.FormatMemory(perGPU.Framework)
+			resp.PerGPUMemory = calc.FormatMemory(perGPU.PerGPU)
+			resp.PerGPUBreakdown = perGPU.Format()
+			perGPUMemoryGB := perGPU.PerGPU / (1024 * 1024 * 1024)
+			resp.TrainingGPUs = gpu.GetPerDeviceRecommendations(perGPUMemoryGB, r.DataParallelSize, true)
+			if opts.Offload.Enabled() {
+				resp.Offload = r.offloadSummary(perGPU)
+				hostMemoryGB := perGPU.Host / (1024 * 1024 * 1024)
+				resp.TrainingGPUs = gpu.GetOffloadRecommendations(perGPUMemoryGB, hostMemoryGB, r.DataParallelSize, r.HostMemoryPerGPUGB)
+			}
 		}
-		trainingMemoryGB := trainingMemoryBytes / (1024 * 1024 * 1024)
-		resp.TrainingGPUs = gpu.GetGPURecommendations(trainingMemoryGB, true)
 		if len(resp.TrainingGPUs) > 3 {
 			resp.TrainingGPUs = resp.TrainingGPUs[:3]
 		}
 	}
 
-	resp.TotalParams = r.ModelSize * 1e9
+	if r.Parallelism != nil {
+		plan, planFootprint := buildParallelPlan(&r, *r.Parallelism)
+		resp.ParallelPlan = &plan
+		fp.Plan = planFootprint
+		perGPUMemoryGB := plan.PerGPUBytes / (1024 * 1024 * 1024)
+		if r.Optimizer != "" {
+			resp.TrainingGPUs = gpu.GetPerDeviceRecommendations(perGPUMemoryGB, plan.NumGPUs, true)
+			resp.TrainingFrameworkOverhead = calc.FormatMemory(planFootprint.Framework)
+		} else {
+			resp.InferenceGPUs = gpu.GetPerDeviceRecommendations(perGPUMemoryGB, plan.NumGPUs, false)
+			resp.FrameworkOverhead = calc.FormatMemory(planFootprint.Framework)
+		}
+	}
+
+	if r.Optimizer != "" && r.TrainingTokens > 0 {
+		resp.TrainingEstimate = r.trainingEstimate(resp.TrainingGPUs, fp.Training.Total())
+	}
+
+	gpu.AttachInferenceEstimates(resp.InferenceGPUs, r.inferenceWorkload(), gpu.LatencySLO{
+		MaxTTFTMs:          r.TTFTSLOMs,
+		MinDecodeTokensSec: r.DecodeSLOTokens,
+	})
+
+	componentParams := calc.GetComponentParams(spec, false)
+	resp.TotalParams = spec.TotalParams() + componentParams
+	resp.WeightBitsPerParam = calc.GetWeightFootprint(spec, r.TorchDtype) * 8 / spec.TotalParams()
+	resp.QuantizationScheme = spec.QuantScheme
+	resp.DeclaredParams = r.ModelSize * 1e9
+	if spec.CanCountParams() {
+		breakdown := calc.CountParameters(spec)
+		resp.ParamBreakdown = &breakdown
+		resp.ParamGap = calc.GetParamGap(spec)
+		if math.Abs(resp.ParamGap) > calc.ParamMismatchThreshold {
+			resp.ParamWarning = fmt.Sprintf("derived parameter count %.2fB differs from declared model_size %.2fB by %.1f%%", (breakdown.Total+componentParams)/1e9, r.ModelSize, resp.ParamGap)
+		}
+	}
+	resp.ActiveParams = calc.GetActiveParams(spec) + componentParams
+	resp.FlopsPerToken = calc.GetFlopsPerToken(spec)
+	if spec.IsMoE() {
+		expertParams := calc.GetExpertParams(spec, r.NumLocalExperts)
+		resp.ExpertShare = expertParams / resp.TotalParams * 100
+		if !spec.CanCountParams() && expertParams >= resp.DeclaredParams {
+			resp.ParamWarning = fmt.Sprintf("declared model_size %.2fB is smaller than its %.2fB expert parameters", r.ModelSize, expertParams/1e9)
+		}
+	}
 	resp.HiddenSize = r.HiddenSize
+	resp.NumKeyValueHeads = r.NumKeyValueHeads
+	resp.HeadDim = spec.HeadDim()
 	resp.SequenceLength = r.SequenceLength
-	return &resp, nil
+	return &resp, fp, nil
+}
+
+func (r *MemoryRequest) modelSpec() calc.ModelSpec {
+	spec := calc.ModelSpec{
+		ModelSize:         r.ModelSize,
+		VocabSize:         r.VocabSize,
+		HiddenSize:        r.HiddenSize,
+		IntermediateSize:  r.IntermediateSize,
+		NumLayers:         r.NumHiddenLayers,
+		NumHeads:          r.NumAttentionHeads,
+		NumKVHeads:        r.NumKeyValueHeads,
+		HeadSize:          r.HeadDim,
+		HiddenAct:         r.HiddenAct,
+		TieWordEmbeddings: r.TieWordEmbeddings,
+		ScalarHead:        r.scalarHead,
+		AttentionBias:     r.AttentionBias,
+		MLPBias:           r.MLPBias,
+		NumExperts:        r.NumLocalExperts,
+		NumExpertsPerTok:  r.NumExpertsPerTok,
+		KVLoRARank:        r.KVLoRARank,
+		QLoRARank:         r.QLoRARank,
+		QKRopeHeadDim:     r.QKRopeHeadDim,
+		QKNopeHeadDim:     r.QKNopeHeadDim,
+		VHeadDim:          r.VHeadDim,
+		Blocks:            r.layerBlocks(),
+		SSM: calc.SSMConfig{
+			DState: r.MambaDState,
+			DConv:  r.MambaDConv,
+			Expand: r.MambaExpand,
+			DTRank: r.MambaDTRank,
+		},
+	}
+	if r.UseSlidingWindow == nil || *r.UseSlidingWindow {
+		spec.SlidingWindow = r.SlidingWindow
+		spec.LayerTypes = r.attentionLayerTypes()
+	}
+	if r.QuantizationConfig != nil {
+		spec.QuantScheme, _ = r.QuantizationConfig.Scheme()
+		spec.ModulesToNotConvert = r.QuantizationConfig.ExcludedModules()
+	}
+	spec.Components = r.components()
+	return spec
+}
+
+// components builds the encoder, which inherits the decoder's activation, and a GELU ViT vision tower.
+func (r *MemoryRequest) components() []calc.Component {
+	var components []calc.Component
+	if e := r.Encoder; e != nil {
+		spec := componentSpec(*e, r.HiddenAct, r.AttentionBias, r.MLPBias)
+		components = append(components, calc.NewEncoderComponent(spec, r.componentDtype(*e), r.EncoderSeqLength, componentTrainable(*e, true)))
+	}
+	if v := r.VisionConfig; v != nil {
+		spec := componentSpec(*v, "gelu", true, true)
+		components = append(components, calc.NewVisionComponent(spec, r.componentDtype(*v), v.ImageSize, v.PatchSize, r.NumImages, r.HiddenSize, componentTrainable(*v, false)))
+	}
+	return components
+}
+
+func componentSpec(c config.ComponentConfig, hiddenAct string, attentionBias, mlpBias bool) calc.ModelSpec {
+	if c.HiddenAct != "" {
+		hiddenAct = c.HiddenAct
+	}
+	kvHeads := c.NumKeyValueHeads
+	if kvHeads == 0 {
+		kvHeads = c.NumAttentionHeads
+	}
+	return calc.ModelSpec{
+		HiddenSize:       c.HiddenSize,
+		IntermediateSize: c.IntermediateSize,
+		NumLayers:        c.NumHiddenLayers,
+		NumHeads:         c.NumAttentionHeads,
+		NumKVHeads:       kvHeads,
+		HeadSize:         c.HeadDim,
+		HiddenAct:        hiddenAct,
+		AttentionBias:    attentionBias,
+		MLPBias:          mlpBias,
+	}
+}
+
+func (r *MemoryRequest) componentDtype(c config.ComponentConfig) string {
+	if c.TorchDtype != "" {
+		return c.TorchDtype
+	}
+	return r.TorchDtype
+}
+
+func componentTrainable(c config.ComponentConfig, fallback bool) bool {
+	if c.Trainable != nil {
+		return *c.Trainable
+	}
+	return fallback
+}
+
+// imageTokens is the decoder positions one sample's images take, one per patch unless image_seq_length is set.
+func (r *MemoryRequest) imageTokens() int {
+	if r.VisionConfig == nil {
+		return 0
+	}
+	perImage := r.ImageSeqLength
+	if perImage == 0 {
+		perImage = calc.ImagePatches(r.VisionConfig.ImageSize, r.VisionConfig.PatchSize)
+	}
+	return r.NumImages * perImage
 }
 
-func parseMemoryString(memStr string) (float64, error) {
-	var value float64
-	var unit string
-	_, err := fmt.Sscanf(memStr, "%f %s", &value, &unit)
-	if err != nil {
-		return 0, err
+// attentionLayerTypes expands a sliding_window_pattern or max_window_layers into per-layer types.
+func (r *MemoryRequest) attentionLayerTypes() []string {
+	if len(r.LayerTypes) > 0 || (r.SlidingWindowPattern <= 0 && r.MaxWindowLayers <= 0) {
+		return r.LayerTypes
 	}
+	types := make([]string, r.NumHiddenLayers)
+	for i := range types {
+		types[i] = calc.LayerSlidingAttention
+		if r.SlidingWindowPattern > 0 && (i+1)%r.SlidingWindowPattern == 0 {
+			types[i] = calc.LayerFullAttention
+		}
+		if r.MaxWindowLayers > 0 && i < r.MaxWindowLayers {
+			types[i] = calc.LayerFullAttention
+		}
+	}
+	return types
+}
+
+// layerBlocks resolves the blocks of hybrid and state-space models, or nil for plain transformers.
+func (r *MemoryRequest) layerBlocks() []calc.LayerBlocks {
+	pureMamba := r.MambaDState > 0 && r.NumAttentionHeads == 0
+	if len(r.LayersBlockType) == 0 && len(r.LayersFFNType) == 0 && r.AttnLayerPeriod <= 0 && r.ExpertLayerPeriod <= 0 && !pureMamba {
+		return nil
+	}
+	blocks := make([]calc.LayerBlocks, r.NumHiddenLayers)
+	for i := range blocks {
+		mixer := calc.BlockAttention
+		switch {
+		case len(r.LayersBlockType) > 0:
+			mixer = r.LayersBlockType[i]
+		case r.AttnLayerPeriod > 0 && i%r.AttnLayerPeriod != r.AttnLayerOffset:
+			mixer = calc.BlockMamba
+		case pureMamba:
+			mixer = calc.BlockMamba
+		}
+		ffn := calc.BlockMLP
+		switch {
+		case len(r.LayersFFNType) > 0:
+			ffn = r.LayersFFNType[i]
+		case r.ExpertLayerPeriod > 0:
+			if i%r.ExpertLayerPeriod == r.ExpertLayerOffset {
+				ffn = calc.BlockMoE
+			}
+		case r.NumLocalExperts > 1:
+			ffn = calc.BlockMoE
+		case pureMamba && len(r.LayersBlockType) == 0:
+			ffn = calc.BlockNone
+		}
+		blocks[i] = calc.LayerBlocks{Mixer: mixer, FeedForward: ffn}
+	}
+	return blocks
+}
+
+func applyRequestDefaults(r *MemoryRequest) {
+	if r.NumKeyValueHeads == 0 {
+		r.NumKeyValueHeads = r.NumAttentionHeads
+	}
+	if r.MFU == 0 {
+		r.MFU = gpu.DefaultMFU
+	}
+	if r.Encoder != nil && r.EncoderSeqLength == 0 {
+		r.EncoderSeqLength = r.SequenceLength
+	}
+	// Image tokens sit in the decoder's context ahead of the text prompt.
+	if images := r.imageTokens(); images > 0 {
+		r.SequenceLength += images
+		if r.PromptLength > 0 {
+			r.PromptLength += images
+		}
+	}
+	if r.PromptLength == 0 || r.PromptLength > r.SequenceLength {
+		r.PromptLength = r.SequenceLength
+	}
+	if r.FineTuneMethod == "" {
+		r.FineTuneMethod = calc.FineTuneFull
+	}
+	if len(r.LoRATargetModules) == 0 {
+		r.LoRATargetModules = calc.LoRATargetsFor(r.modelSpec())
+	}
+	if r.LoRABasePrecision == "" {
+		r.LoRABasePrecision = "nf4"
+	}
+	if r.Checkpointing == "" {
+		r.Checkpointing = calc.CheckpointNone
+	}
+	r.KVCacheDtype = r.kvCacheOptions().WithDefaults(r.TorchDtype).Dtype
+	if r.DataParallelSize == 0 {
+		r.DataParallelSize = 1
+	}
+	if r.ShardingStrategy == calc.ShardHybrid && r.ShardGroupSize == 0 {
+		r.ShardGroupSize = calc.DefaultHybridShardGroup
+		if r.DataParallelSize < r.ShardGroupSize {
+			r.ShardGroupSize = r.DataParallelSize
+		}
+	}
+	if r.PCIeBandwidthGBs == 0 {
+		r.PCIeBandwidthGBs = calc.DefaultPCIeBandwidthGBs
+	}
+	if r.Framework == "" {
+		r.Framework = calc.FrameworkPyTorch
+	}
+}
+
+// frameworkOverhead is the framework's default per-GPU overhead with the
+// request's overrides applied.
+func (r *MemoryRequest) frameworkOverhead() calc.FrameworkOverhead {
+	overhead := calc.FrameworkOverheads[r.Framework]
+	if r.FrameworkOverheadGB != nil {
+		overhead.Fixed = *r.FrameworkOverheadGB * 1024 * 1024 * 1024
+	}
+	if r.Fragmentation != nil {
+		overhead.Fragmentation = *r.Fragmentation
+	}
+	return overhead
+}
+
+func (r *MemoryRequest) deviceOverhead() gpu.DeviceOverhead {
+	overhead := r.frameworkOverhead()
+	return gpu.DeviceOverhead{
+		FixedGB:       overhead.Fixed / (1024 * 1024 * 1024),
+		Fragmentation: overhead.Fragmentation,
+	}
+}
+
+// deviceFrameworkOverhead is what each GPU of the top recommendation pays when modelBytes is split evenly across it.
+func (r *MemoryRequest) deviceFrameworkOverhead(modelBytes float64, recommendations []gpu.GPURecommendation) float64 {
+	numGPUs := 1
+	if len(recommendations) > 0 {
+		numGPUs = recommendations[0].NumGPUs
+	}
+	return r.frameworkOverhead().PerGPU(modelBytes / float64(numGPUs))
+}
+
+func (r *MemoryRequest) activationOptions() calc.ActivationOptions {
+	return calc.ActivationOptions{
+		Checkpointing:     r.Checkpointing,
+		FlashAttention:    r.FlashAttention,
+		FusedCrossEntropy: r.FusedCrossEntropy,
+	}
+}
 
-	multiplier := 1.0
-	switch unit {
-	case "TB":
-		multiplier = 1024 * 1024 * 1024 * 1024
-	case "GB":
-		multiplier = 1024 * 1024 * 1024
-	case "MB":
-		multiplier = 1024 * 1024
-	case "KB":
-		multiplier = 1024
+// trainablePercent defaults an absent trainable_params to 100; an explicit 0 freezes the model.
+func (r *MemoryRequest) trainablePercent() float64 {
+	if r.TrainableParams == nil {
+		return 100
 	}
+	return *r.TrainableParams
+}
 
-	return value * multiplier, nil
+func (r *MemoryRequest) kvCacheOptions() calc.KVCacheOptions {
+	return calc.KVCacheOptions{
+		Dtype:           r.KVCacheDtype,
+		OffloadFraction: r.KVOffloadFraction,
+	}
+}
+
+func (r *MemoryRequest) trainingOptions() calc.TrainingOptions {
+	return calc.TrainingOptions{
+		Optimizer:        r.Optimizer,
+		TrainablePercent: r.trainablePercent(),
+		Method:           r.FineTuneMethod,
+		LoRARank:         r.LoRARank,
+		LoRATargets:      r.LoRATargetModules,
+		BasePrecision:    r.LoRABasePrecision,
+		Activation:       r.activationOptions(),
+		Policy:           r.PrecisionPolicy,
+		KVCache:          r.kvCacheOptions(),
+		Overhead:         r.frameworkOverhead(),
+		Sharding: calc.ShardingOptions{
+			Strategy:     r.ShardingStrategy,
+			DataParallel: r.DataParallelSize,
+			ShardGroup:   r.ShardGroupSize,
+		},
+		Offload: calc.OffloadOptions{
+			Optimizer: r.OffloadOptimizer,
+			Params:    r.OffloadParams,
+		},
+	}
+}
+
+// offloadSummary reports where an offloaded job's per-GPU state lives and its PCIe time per step.
+func (r *MemoryRequest) offloadSummary(m calc.TrainingFootprint) *OffloadSummary {
+	return &OffloadSummary{
+		OptimizerTarget:    r.OffloadOptimizer,
+		ParamsTarget:       r.OffloadParams,
+		GPUMemory:          calc.FormatMemory(m.PerGPU),
+		HostMemory:         calc.FormatMemory(m.Host),
+		NVMeMemory:         calc.FormatMemory(m.NVMe),
+		PCIeTrafficPerStep: calc.FormatMemory(m.PCIeTraffic),
+		PCIeBandwidthGBs:   r.PCIeBandwidthGBs,
+		PCIeTimePerStepMs:  m.PCIeTraffic / (r.PCIeBandwidthGBs * 1e9) * 1000,
+		NVMeTrafficPerStep: calc.FormatMemory(m.NVMeTraffic),
+	}
+}
+
+func (r *MemoryRequest) inferenceWorkload() gpu.InferenceWorkload {
+	spec := r.modelSpec()
+	return gpu.InferenceWorkload{
+		BatchSize:           r.BatchSize,
+		PromptLength:        r.PromptLength,
+		ContextLength:       r.SequenceLength,
+		PrefillWeightBytes:  calc.GetWeightFootprint(spec, r.TorchDtype) + calc.GetComponentWeights(spec),
+		DecodeWeightBytes:   calc.GetDecodeWeightBytes(spec, r.TorchDtype, r.BatchSize),
+		KVBytesPerToken:     calc.GetKVCacheBreakdown(spec, 1, r.SequenceLength, r.KVCacheDtype).Total / float64(r.SequenceLength),
+		FlopsPerToken:       calc.GetFlopsPerToken(spec),
+		AttentionFlopsPerKV: calc.GetAttentionFlopsPerKV(spec),
+	}
+}
+
+// trainingEstimate is nil when no training_gpu is named and no catalog GPU fits the run.
+func (r *MemoryRequest) trainingEstimate(recommendations []gpu.GPURecommendation, trainingBytes float64) *gpu.TrainingEstimate {
+	var device gpu.GPUSpec
+	numGPUs := r.TrainingNumGPUs
+	if r.TrainingGPU != "" {
+		device, _ = gpu.FindGPU(r.TrainingGPU)
+	} else if len(recommendations) > 0 {
+		device = recommendations[0].GPU
+		if numGPUs == 0 {
+			numGPUs = recommendations[0].NumGPUs
+		}
+	} else {
+		return nil
+	}
+	if numGPUs == 0 {
+		switch {
+		case r.Parallelism != nil:
+			numGPUs = r.Parallelism.WithDefaults().GPUs()
+		case r.ShardingStrategy != "" || r.OffloadOptimizer != "":
+			numGPUs = r.DataParallelSize
+		default:
+			numGPUs = r.deviceOverhead().GPUsFor(device, trainingBytes/(1024*1024*1024))
+		}
+	}
+	if numGPUs == 0 {
+		numGPUs = 1
+	}
+	flopsPerToken := calc.GetTrainingFlopsPerToken(r.modelSpec(), r.SequenceLength, r.trainingOptions())
+	estimate := gpu.EstimateTraining(device, numGPUs, r.TrainingTokens, flopsPerToken*r.TrainingTokens, r.MFU, r.GPUHourlyRate)
+	return &estimate
+}
+
+func validateLayerBlocks(req *MemoryRequest) error {
+	if len(req.LayersBlockType) > 0 && len(req.LayersBlockType) != req.NumHiddenLayers {
+		return fmt.Errorf("layers_block_type has %d entries for %d layers", len(req.LayersBlockType), req.NumHiddenLayers)
+	}
+	if len(req.LayersFFNType) > 0 && len(req.LayersFFNType) != req.NumHiddenLayers {
+		return fmt.Errorf("layers_ffn_type has %d entries for %d layers", len(req.LayersFFNType), req.NumHiddenLayers)
+	}
+	for _, block := range req.LayersBlockType {
+		if block != calc.BlockAttention && block != calc.BlockMamba {
+			return fmt.Errorf("invalid layer block type: %s", block)
+		}
+	}
+	for _, block := range req.LayersFFNType {
+		if block != calc.BlockMLP && block != calc.BlockMoE && block != calc.BlockNone {
+			return fmt.Errorf("invalid layer feed-forward type: %s", block)
+		}
+	}
+	if req.AttnLayerPeriod < 0 || req.AttnLayerOffset < 0 || req.ExpertLayerPeriod < 0 || req.ExpertLayerOffset < 0 {
+		return fmt.Errorf("layer periods and offsets must not be negative")
+	}
+	if req.MambaDState < 0 || req.MambaDConv < 0 || req.MambaExpand < 0 || req.MambaDTRank < 0 {
+		return fmt.Errorf("mamba settings must not be negative")
+	}
+	spec := req.modelSpec()
+	if spec.IsHybrid() && (req.MambaDState == 0 || req.MambaDConv == 0 || req.MambaExpand == 0) {
+		return fmt.Errorf("mamba layers require mamba_d_state, mamba_d_conv and mamba_expand")
+	}
+	if spec.CountBlocks(calc.BlockMoE) > 0 && req.NumLocalExperts <= 1 {
+		return fmt.Errorf("moe layers require num_local_experts above 1")
+	}
+	return nil
+}
+
+func validateComponents(req *MemoryRequest) error {
+	if req.EncoderSeqLength < 0 || req.NumImages < 0 || req.ImageSeqLength < 0 {
+		return fmt.Errorf("encoder sequence length, image count and image tokens must not be negative")
+	}
+	if req.NumImages > 0 && req.VisionConfig == nil {
+		return fmt.Errorf("num_images requires a vision_config")
+	}
+	names := []string{"encoder", "vision_config"}
+	for i, c := range []*config.ComponentConfig{req.Encoder, req.VisionConfig} {
+		name := names[i]
+		if c == nil {
+			continue
+		}
+		if c.HiddenSize <= 0 || c.NumHiddenLayers <= 0 || c.NumAttentionHeads <= 0 || c.IntermediateSize <= 0 {
+			return fmt.Errorf("%s requires positive hidden_size, intermediate_size, num_hidden_layers and num_attention_heads", name)
+		}
+		if c.NumKeyValueHeads < 0 || c.HeadDim < 0 {
+			return fmt.Errorf("%s key-value heads and head dim must not be negative", name)
+		}
+		if c.NumKeyValueHeads > 0 && c.NumAttentionHeads%c.NumKeyValueHeads != 0 {
+			return fmt.Errorf("%s attention heads (%d) must be divisible by key-value heads (%d)", name, c.NumAttentionHeads, c.NumKeyValueHeads)
+		}
+		if _, ok := config.QuantSchemes[c.TorchDtype]; c.TorchDtype != "" && !ok {
+			return fmt.Errorf("invalid %s precision type: %s", name, c.TorchDtype)
+		}
+	}
+	if v := req.VisionConfig; v != nil && (v.PatchSize <= 0 || v.ImageSize < v.PatchSize) {
+		return fmt.Errorf("vision_config requires a positive patch_size no larger than image_size")
+	}
+	if req.Parallelism != nil && (req.Encoder != nil || req.VisionConfig != nil) {
+		return fmt.Errorf("parallelism layouts do not support encoder or vision components")
+	}
+	return nil
 }
 
 func validateRequest(req *MemoryRequest) error {
@@ -101,9 +618,47 @@ func validateRequest(req *MemoryRequest) error {
 	if req.NumHiddenLayers <= 0 {
 		return fmt.Errorf("number of layers must be positive")
 	}
-	if req.NumAttentionHeads <= 0 {
+	if err := validateLayerBlocks(req); err != nil {
+		return err
+	}
+	spec := req.modelSpec()
+	if req.NumAttentionHeads <= 0 && spec.CountBlocks(calc.BlockAttention) > 0 {
 		return fmt.Errorf("number of attention heads must be positive")
 	}
+	if req.NumKeyValueHeads < 0 {
+		return fmt.Errorf("number of key-value heads must not be negative")
+	}
+	if req.NumKeyValueHeads > 0 && req.NumAttentionHeads%req.NumKeyValueHeads != 0 {
+		return fmt.Errorf("number of attention heads (%d) must be divisible by number of key-value heads (%d)", req.NumAttentionHeads, req.NumKeyValueHeads)
+	}
+	if req.NumLocalExperts > 1 {
+		if req.NumExpertsPerTok <= 0 || req.NumExpertsPerTok > req.NumLocalExperts {
+			return fmt.Errorf("experts per token must be between 1 and %d", req.NumLocalExperts)
+		}
+		if req.IntermediateSize <= 0 {
+			return fmt.Errorf("intermediate size must be positive for mixture-of-experts models")
+		}
+	}
+	if req.PromptLength < 0 || req.TTFTSLOMs < 0 || req.DecodeSLOTokens < 0 {
+		return fmt.Errorf("prompt length and latency targets must not be negative")
+	}
+	if req.TrainingTokens < 0 || req.TrainingNumGPUs < 0 || req.GPUHourlyRate < 0 {
+		return fmt.Errorf("training tokens, GPU count and hourly rate must not be negative")
+	}
+	if req.MFU < 0 || req.MFU > 1 {
+		return fmt.Errorf("mfu must be between 0 and 1")
+	}
+	if _, ok := calc.Optimizers[req.Optimizer]; req.Optimizer != "" && !ok {
+		return fmt.Errorf("unknown optimizer %q, valid optimizers: %s", req.Optimizer, strings.Join(calc.OptimizerNames(), ", "))
+	}
+	if req.TrainingGPU != "" {
+		if _, ok := gpu.FindGPU(req.TrainingGPU); !ok {
+			return fmt.Errorf("unknown training GPU %q, valid GPUs: %s", req.TrainingGPU, strings.Join(gpu.GPUNames(), ", "))
+		}
+	}
+	if req.VocabSize < 0 || req.HeadDim < 0 {
+		return fmt.Errorf("vocab size and head dim must not be negative")
+	}
 	if req.SequenceLength <= 0 {
 		return fmt.Errorf("sequence length must be positive")
 	}
@@ -111,15 +666,169 @@ func validateRequest(req *MemoryRequest) error {
 		return fmt.Errorf("batch size must be positive")
 	}
 
-	validDtypes := map[string]bool{
-		"float32":  true,
-		"float16":  true,
-		"bfloat16": true,
-		"int8":     true,
-		"int4":     true,
-	}
-	if !validDtypes[req.TorchDtype] {
+	if _, ok := config.QuantSchemes[req.TorchDtype]; !ok {
 		return fmt.Errorf("invalid precision type: %s", req.TorchDtype)
 	}
+	if req.SlidingWindow < 0 || req.SlidingWindowPattern < 0 || req.MaxWindowLayers < 0 {
+		return fmt.Errorf("sliding window settings must not be negative")
+	}
+	if len(req.LayerTypes) > 0 {
+		if len(req.LayerTypes) != req.NumHiddenLayers {
+			return fmt.Errorf("layer_types has %d entries for %d layers", len(req.LayerTypes), req.NumHiddenLayers)
+		}
+		for _, layerType := range req.LayerTypes {
+			if layerType != calc.LayerFullAttention && layerType != calc.LayerSlidingAttention {
+				return fmt.Errorf("invalid layer type: %s", layerType)
+			}
+		}
+	}
+	if req.KVLoRARank < 0 || req.QLoRARank < 0 || req.QKRopeHeadDim < 0 || req.QKNopeHeadDim < 0 || req.VHeadDim < 0 {
+		return fmt.Errorf("latent attention dimensions must not be negative")
+	}
+	if req.KVLoRARank > 0 && (req.QKRopeHeadDim == 0 || req.QKNopeHeadDim == 0) {
+		return fmt.Errorf("kv_lora_rank requires qk_rope_head_dim and qk_nope_head_dim")
+	}
+	if q := req.QuantizationConfig; q != nil {
+		if _, ok := q.Scheme(); !ok {
+			return fmt.Errorf("unsupported quantization_config: quant_method %q with %d bits and group size %d", q.QuantMethod, q.Bits, q.GroupSize)
+		}
+	}
+	if _, ok := calc.KVCacheFormats[req.KVCacheDtype]; req.KVCacheDtype != "" && !ok {
+		return fmt.Errorf("invalid kv cache dtype: %s", req.KVCacheDtype)
+	}
+	if req.KVOffloadFraction < 0 || req.KVOffloadFraction >= 1 {
+		return fmt.Errorf("kv offload fraction must be at least 0 and below 1")
+	}
+	switch req.Checkpointing {
+	case "", calc.CheckpointNone, calc.CheckpointSelective, calc.CheckpointFull:
+	default:
+		return fmt.Errorf("invalid activation checkpointing mode: %s", req.Checkpointing)
+	}
+	policy := req.PrecisionPolicy
+	if _, ok := config.QuantSchemes[policy.Weights]; policy.Weights != "" && !ok {
+		return fmt.Errorf("invalid precision policy dtype: %s", policy.Weights)
+	}
+	for _, dtype := range []string{policy.Gradients, policy.Optimizer} {
+		if dtype != "" && !isPlainDtype(dtype) {
+			return fmt.Errorf("invalid precision policy dtype: %s", dtype)
+		}
+	}
+	if policy.Master != "" && policy.Master != calc.NoMasterWeights && !isPlainDtype(policy.Master) {
+		return fmt.Errorf("invalid master weights dtype: %s", policy.Master)
+	}
+	if err := validateComponents(req); err != nil {
+		return err
+	}
+	if err := validateSharding(req); err != nil {
+		return err
+	}
+	if err := validateParallelism(req); err != nil {
+		return err
+	}
+	if err := validateOffload(req); err != nil {
+		return err
+	}
+	if err := validateFramework(req); err != nil {
+		return err
+	}
+	return validateFineTune(req)
+}
+
+// isPlainDtype rejects weight-only quantization schemes for gradients, master weights and optimizer states.
+func isPlainDtype(dtype string) bool {
+	scheme, ok := config.QuantSchemes[dtype]
+	return ok && !scheme.IsQuantized()
+}
+
+func validateSharding(req *MemoryRequest) error {
+	if req.DataParallelSize < 0 || req.ShardGroupSize < 0 {
+		return fmt.Errorf("data parallel and shard group sizes must not be negative")
+	}
+	if req.ShardingStrategy == "" {
+		return nil
+	}
+	if !calc.ShardingStrategies[req.ShardingStrategy] {
+		return fmt.Errorf("invalid sharding strategy: %s", req.ShardingStrategy)
+	}
+	if req.ShardingStrategy == calc.ShardHybrid && req.ShardGroupSize > 0 && req.DataParallelSize > 0 && req.DataParallelSize%req.ShardGroupSize != 0 {
+		return fmt.Errorf("data parallel size (%d) must be divisible by shard group size (%d)", req.DataParallelSize, req.ShardGroupSize)
+	}
+	return nil
+}
+
+// validateOffload follows DeepSpeed: parameter offload needs the parameters to be partitioned.
+func validateOffload(req *MemoryRequest) error {
+	if req.PCIeBandwidthGBs < 0 || req.HostMemoryPerGPUGB < 0 {
+		return fmt.Errorf("pcie bandwidth and host memory per GPU must not be negative")
+	}
+	if req.OffloadOptimizer == "" && req.OffloadParams == "" {
+		return nil
+	}
+	for _, target := range []string{req.OffloadOptimizer, req.OffloadParams} {
+		if target != "" && !calc.OffloadTargets[target] {
+			return fmt.Errorf("invalid offload target %q, valid targets: %s, %s", target, calc.OffloadCPU, calc.OffloadNVMe)
+		}
+	}
+	if req.Optimizer == "" {
+		return fmt.Errorf("offload requires an optimizer")
+	}
+	if req.Parallelism != nil {
+		return fmt.Errorf("offload is not supported with a parallelism layout")
+	}
+	if req.OffloadParams != "" && req.ShardingStrategy != calc.ShardZeRO3 && req.ShardingStrategy != calc.ShardFSDP && req.ShardingStrategy != calc.ShardHybrid {
+		return fmt.Errorf("offload_params requires sharding_strategy zero3, fsdp or hybrid")
+	}
+	return nil
+}
+
+func validateFramework(req *MemoryRequest) error {
+	if _, ok := calc.FrameworkOverheads[req.Framework]; req.Framework != "" && !ok {
+		return fmt.Errorf("unknown framework %q, valid frameworks: %s", req.Framework, strings.Join(calc.FrameworkNames(), ", "))
+	}
+	if req.FrameworkOverheadGB != nil && *req.FrameworkOverheadGB < 0 {
+		return fmt.Errorf("framework overhead must not be negative")
+	}
+	if req.Fragmentation != nil && (*req.Fragmentation < 0 || *req.Fragmentation >= 1) {
+		return fmt.Errorf("fragmentation must be between 0 and 1")
+	}
+	return nil
+}
+
+func validateFineTune(req *MemoryRequest) error {
+	if p := req.TrainableParams; p != nil && (*p < 0 || *p > 100) {
+		return fmt.Errorf("trainable params must be a percentage between 0 and 100")
+	}
+	switch req.FineTuneMethod {
+	case "", calc.FineTuneFull:
+		return nil
+	case calc.FineTuneLoRA, calc.FineTuneQLoRA:
+	default:
+		return fmt.Errorf("invalid fine-tune method: %s", req.FineTuneMethod)
+	}
+	if req.LoRARank <= 0 {
+		return fmt.Errorf("lora rank must be positive")
+	}
+	spec := req.modelSpec()
+	if spec.NumKVHeads == 0 {
+		spec.NumKVHeads = spec.NumHeads
+	}
+	targets := req.LoRATargetModules
+	if len(targets) == 0 {
+		targets = calc.LoRATargetsFor(spec)
+	}
+	for _, module := range targets {
+		if !calc.LoRATargetModules[module] {
+			return fmt.Errorf("invalid lora target module: %s", module)
+		}
+		if !calc.HasLoRAModule(spec, module) {
+			return fmt.Errorf("lora target module %s does not exist in this model", module)
+		}
+	}
+	if calc.GetLoRAParams(spec, req.LoRARank, targets) == 0 {
+		return fmt.Errorf("lora target modules %s train no parameters", strings.Join(targets, ", "))
+	}
+	if _, ok := config.QuantSchemes[req.LoRABasePrecision]; req.LoRABasePrecision != "" && !ok {
+		return fmt.Errorf("invalid lora base precision: %s", req.LoRABasePrecision)
+	}
 	return nil
 }
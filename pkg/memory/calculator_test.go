@@ -0,0 +1,705 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/config"
+	"compute-gauge/pkg/gpu"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestExpertParamsWarning(t *testing.T) {
+	// A GELU MoE has two matrices per expert: 12.9B expert parameters.
+	tests := []struct {
+		name      string
+		hiddenAct string
+		modelSize float64
+		warn      bool
+	}{
+		{"non-gated experts fit a 13.4B model", "gelu", 13.4, false},
+		{"non-gated experts exceed a 12B model", "gelu", 12, true},
+		{"gated experts exceed a 13.4B model", "silu", 13.4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := MemoryRequest{
+				ModelSize:         tt.modelSize,
+				HiddenSize:        2048,
+				IntermediateSize:  8192,
+				NumHiddenLayers:   24,
+				NumAttentionHeads: 16,
+				HiddenAct:         tt.hiddenAct,
+				NumLocalExperts:   16,
+				NumExpertsPerTok:  2,
+				SequenceLength:    4096,
+				BatchSize:         1,
+				TorchDtype:        "bfloat16",
+			}
+			resp, err := CalculateMemoryRequirements(&r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.ParamWarning != ""; got != tt.warn {
+				t.Errorf("param warning = %q, want warning %v", resp.ParamWarning, tt.warn)
+			}
+			if resp.ActiveParams <= 0 {
+				t.Errorf("active params = %.0f, want positive", resp.ActiveParams)
+			}
+		})
+	}
+}
+
+func TestTrainablePercent(t *testing.T) {
+	zero, half := 0.0, 50.0
+	tests := []struct {
+		name      string
+		trainable *float64
+		want      float64
+	}{
+		{"absent defaults to full", nil, 100},
+		{"explicit zero freezes", &zero, 0},
+		{"explicit share", &half, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := MemoryRequest{TrainableParams: tt.trainable}
+			if got := r.trainablePercent(); got != tt.want {
+				t.Errorf("trainable percent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRequestPrecisionPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy calc.PrecisionPolicy
+		valid  bool
+	}{
+		{"defaults", calc.PrecisionPolicy{}, true},
+		{"no master copy", calc.PrecisionPolicy{Master: calc.NoMasterWeights}, true},
+		{"bf16 gradients", calc.PrecisionPolicy{Gradients: "bfloat16"}, true},
+		{"unknown gradient dtype", calc.PrecisionPolicy{Gradients: "float8"}, false},
+		{"unknown master dtype", calc.PrecisionPolicy{Master: "double"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := MemoryRequest{
+				ModelSize:         7,
+				HiddenSize:        4096,
+				NumHiddenLayers:   32,
+				NumAttentionHeads: 32,
+				SequenceLength:    2048,
+				BatchSize:         1,
+				TorchDtype:        "bfloat16",
+				Optimizer:         "AdamW",
+				PrecisionPolicy:   tt.policy,
+			}
+			err := validateRequest(&r)
+			if (err == nil) != tt.valid {
+				t.Errorf("validateRequest = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestHybridShardGroupDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		dp, group int
+		wantGroup int
+		valid     bool
+	}{
+		{"default group of 8", 32, 0, 8, true},
+		{"group capped at the data-parallel size", 4, 0, 4, true},
+		{"explicit group kept", 16, 2, 2, true},
+		{"group must divide the data-parallel size", 12, 8, 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := MemoryRequest{ShardingStrategy: calc.ShardHybrid, DataParallelSize: tt.dp, ShardGroupSize: tt.group}
+			applyRequestDefaults(&r)
+			if r.ShardGroupSize != tt.wantGroup {
+				t.Errorf("shard group = %d, want %d", r.ShardGroupSize, tt.wantGroup)
+			}
+			if err := validateSharding(&r); (err == nil) != tt.valid {
+				t.Errorf("validateSharding = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestTrainingEstimateWithoutFittingGPU(t *testing.T) {
+	// Llama-3-70B with ZeRO-1 over 2 ranks needs far more than any catalog GPU.
+	r := MemoryRequest{
+		ModelSize:         70,
+		VocabSize:         128256,
+		HiddenSize:        8192,
+		IntermediateSize:  28672,
+		NumHiddenLayers:   80,
+		NumAttentionHeads: 64,
+		NumKeyValueHeads:  8,
+		SequenceLength:    8192,
+		BatchSize:         1,
+		TorchDtype:        "bfloat16",
+		Optimizer:         "AdamW",
+		ShardingStrategy:  calc.ShardZeRO1,
+		DataParallelSize:  2,
+		TrainingTokens:    1e9,
+	}
+	resp, err := CalculateMemoryRequirements(&r)
+	if err != nil {
+		t.Fatalf("CalculateMemoryRequirements: %v", err)
+	}
+	if len(resp.TrainingGPUs) != 0 || resp.TrainingEstimate != nil {
+		t.Errorf("got %d training GPUs and estimate %+v, want neither", len(resp.TrainingGPUs), resp.TrainingEstimate)
+	}
+}
+
+func TestTrainingEstimateNamedGPUCount(t *testing.T) {
+	// A Llama-3-70B full fine-tune with AdamW needs over a terabyte, far more than one H100.
+	r := MemoryRequest{
+		ModelSize:         70,
+		VocabSize:         128256,
+		HiddenSize:        8192,
+		IntermediateSize:  28672,
+		NumHiddenLayers:   80,
+		NumAttentionHeads: 64,
+		NumKeyValueHeads:  8,
+		SequenceLength:    4096,
+		BatchSize:         1,
+		TorchDtype:        "bfloat16",
+		Optimizer:         "AdamW",
+		FlashAttention:    true,
+		TrainingTokens:    1e9,
+		TrainingGPU:       "NVIDIA H100-80GB",
+		Framework:         calc.FrameworkPyTorch,
+	}
+	resp, fp, err := calculate(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, _ := gpu.FindGPU(r.TrainingGPU)
+	want := r.deviceOverhead().GPUsFor(device, fp.Training.Total()/(1024*1024*1024))
+	if want < 2 {
+		t.Fatalf("70B AdamW fits on %d H100", want)
+	}
+	if got := resp.TrainingEstimate.NumGPUs; got != want {
+		t.Errorf("training estimate uses %d GPUs, want %d", got, want)
+	}
+}
+
+func TestValidateRequestKVCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		dtype    string
+		fraction float64
+		valid    bool
+	}{
+		{"weight precision", "", 0, true},
+		{"int4 with half offloaded", "int4", 0.5, true},
+		{"unknown dtype", "nf4", 0, false},
+		{"everything offloaded", "", 1, false},
+		{"negative fraction", "", -0.1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := MemoryRequest{
+				ModelSize:         7,
+				HiddenSize:        4096,
+				NumHiddenLayers:   32,
+				NumAttentionHeads: 32,
+				SequenceLength:    2048,
+				BatchSize:         1,
+				TorchDtype:        "bfloat16",
+				KVCacheDtype:      tt.dtype,
+				KVOffloadFraction: tt.fraction,
+			}
+			err := validateRequest(&r)
+			if (err == nil) != tt.valid {
+				t.Errorf("validateRequest = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestPrecisionPolicyRejectsWeightFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy calc.PrecisionPolicy
+		valid  bool
+	}{
+		{"int4 weights", calc.PrecisionPolicy{Weights: "int4"}, true},
+		{"int4 gradients", calc.PrecisionPolicy{Gradients: "int4"}, false},
+		{"int8 optimizer states", calc.PrecisionPolicy{Optimizer: "int8"}, false},
+		{"int8 master weights", calc.PrecisionPolicy{Master: "int8"}, false},
+		{"nf4 gradients", calc.PrecisionPolicy{Gradients: "nf4"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := llama2_7BCapacityRequest("").MemoryRequest
+			r.Optimizer = "AdamW"
+			r.PrecisionPolicy = tt.policy
+			err := validateRequest(&r)
+			if (err == nil) != tt.valid {
+				t.Errorf("validateRequest = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestQuantizationConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.QuantizationConfig
+		scheme string
+	}{
+		{"fbgemm fp8", config.QuantizationConfig{QuantMethod: "fbgemm_fp8"}, "fp8"},
+		{"gptq", config.QuantizationConfig{QuantMethod: "gptq", Bits: 4, GroupSize: 128}, "gptq_int4_g128"},
+		{"awq", config.QuantizationConfig{QuantMethod: "AWQ", Bits: 4, GroupSize: 128}, "awq_int4_g128"},
+		{"bitsandbytes 4-bit", config.QuantizationConfig{QuantMethod: "bitsandbytes", LoadIn4bit: true}, "nf4"},
+		{"bitsandbytes 8-bit", config.QuantizationConfig{QuantMethod: "bitsandbytes", LoadIn8bit: true}, "bnb_int8"},
+		{"gptq 8-bit", config.QuantizationConfig{QuantMethod: "gptq", Bits: 8, GroupSize: 128}, ""},
+		{"unknown method", config.QuantizationConfig{QuantMethod: "hqq"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := llama2_7BCapacityRequest("").MemoryRequest
+			r.QuantizationConfig = &tt.config
+			err := validateRequest(&r)
+			if tt.scheme == "" {
+				if err == nil {
+					t.Error("expected an unsupported quantization_config error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.modelSpec().QuantScheme; got != tt.scheme {
+				t.Errorf("scheme = %q, want %q", got, tt.scheme)
+			}
+		})
+	}
+}
+
+func TestAttentionLayerTypes(t *testing.T) {
+	full, local := calc.LayerFullAttention, calc.LayerSlidingAttention
+	tests := []struct {
+		name    string
+		pattern int
+		maxWin  int
+		want    []string
+	}{
+		{"no pattern leaves every layer local", 0, 0, nil},
+		// Gemma-3: every 3rd layer is global.
+		{"sliding window pattern", 3, 0, []string{local, local, full, local, local, full}},
+		// Qwen2: layers below max_window_layers stay global.
+		{"max window layers", 0, 2, []string{full, full, local, local, local, local}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := MemoryRequest{NumHiddenLayers: 6, SlidingWindow: 1024, SlidingWindowPattern: tt.pattern, MaxWindowLayers: tt.maxWin}
+			got := r.attentionLayerTypes()
+			if len(got) != len(tt.want) {
+				t.Fatalf("layer types = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("layer types = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectAttentionVariant(t *testing.T) {
+	tests := []struct {
+		name                string
+		heads, kvHeads, mla int
+		want                string
+	}{
+		{"Llama-2-7B", 32, 32, 0, config.AttentionMHA},
+		{"Llama-3-8B", 32, 8, 0, config.AttentionGQA},
+		{"Falcon-7B", 71, 1, 0, config.AttentionMQA},
+		{"DeepSeek-V2-Lite", 16, 16, 512, config.AttentionMLA},
+		{"unset KV heads", 32, 0, 0, config.AttentionMHA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.DetectAttentionVariant(tt.heads, tt.kvHeads, tt.mla); got != tt.want {
+				t.Errorf("variant = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayerBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    MemoryRequest
+		counts map[string]int
+	}{
+		{"transformer", MemoryRequest{NumHiddenLayers: 32, NumAttentionHeads: 32}, nil},
+		// Jamba: attention at layer 4 of every 8, MoE at every odd layer.
+		{"jamba periods", MemoryRequest{NumHiddenLayers: 32, NumAttentionHeads: 32, NumLocalExperts: 16, AttnLayerPeriod: 8, AttnLayerOffset: 4, ExpertLayerPeriod: 2, ExpertLayerOffset: 1, MambaDState: 16},
+			map[string]int{calc.BlockAttention: 4, calc.BlockMamba: 28, calc.BlockMLP: 16, calc.BlockMoE: 16}},
+		// Mamba settings without attention heads make a pure Mamba stack.
+		{"pure mamba", MemoryRequest{NumHiddenLayers: 24, MambaDState: 16},
+			map[string]int{calc.BlockMamba: 24, calc.BlockNone: 24}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := tt.req.layerBlocks()
+			if tt.counts == nil {
+				if blocks != nil {
+					t.Errorf("blocks = %v, want nil", blocks)
+				}
+				return
+			}
+			got := map[string]int{}
+			for _, b := range blocks {
+				got[b.Mixer]++
+				got[b.FeedForward]++
+			}
+			for block, want := range tt.counts {
+				if got[block] != want {
+					t.Errorf("%s layers = %d, want %d", block, got[block], want)
+				}
+			}
+		})
+	}
+}
+
+func llavaRequest() MemoryRequest {
+	r := llama2_7BCapacityRequest("").MemoryRequest
+	r.VisionConfig = &config.ComponentConfig{HiddenSize: 1024, IntermediateSize: 4096, NumHiddenLayers: 24, NumAttentionHeads: 16, ImageSize: 336, PatchSize: 14}
+	r.NumImages = 1
+	return r
+}
+
+func TestImageTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		images   int
+		perImage int
+		want     int
+	}{
+		{"one patch per token", 1, 0, 576},
+		{"image_seq_length", 2, 256, 512},
+		{"no images", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := llavaRequest()
+			r.NumImages = tt.images
+			r.ImageSeqLength = tt.perImage
+			if got := r.imageTokens(); got != tt.want {
+				t.Errorf("image tokens = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateMemoryRequirementsIsRepeatable(t *testing.T) {
+	r := llavaRequest()
+	first, err := CalculateMemoryRequirements(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SequenceLength != 4096 || r.PromptLength != 0 || r.KVCacheDtype != "" {
+		t.Fatalf("request was modified: sequence %d, prompt %d, kv dtype %q", r.SequenceLength, r.PromptLength, r.KVCacheDtype)
+	}
+	second, err := CalculateMemoryRequirements(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.InferenceMemory != second.InferenceMemory || first.KVCache != second.KVCache {
+		t.Errorf("second call = %s (KV %s), first %s (KV %s)", second.InferenceMemory, second.KVCache, first.InferenceMemory, first.KVCache)
+	}
+}
+
+func TestOptimizerRegistry(t *testing.T) {
+	tests := []struct {
+		optimizer string
+		memory    string
+		paged     bool
+	}{
+		// 6,738,415,616 parameters × two fp32 moments.
+		{"AdamW", "50.21 GB", false},
+		// Two int8 moments plus one fp32 absmax per 2048 values: 2.0039 bytes per parameter.
+		{"PagedAdamW8bit", "12.58 GB", true},
+		// Row and column vectors: 2,570,752 values for 6,738,149,376 matrix parameters, in fp32.
+		{"Adafactor", "9.81 MB", false},
+		// Six fp32 states per parameter.
+		{"Shampoo", "150.62 GB", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.optimizer, func(t *testing.T) {
+			r := llama2_7BCapacityRequest("").MemoryRequest
+			r.Optimizer = tt.optimizer
+			resp, err := CalculateMemoryRequirements(&r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.OptimizerMemory != tt.memory || resp.OptimizerPaged != tt.paged {
+				t.Errorf("optimizer memory, paged = %s, %v; want %s, %v", resp.OptimizerMemory, resp.OptimizerPaged, tt.memory, tt.paged)
+			}
+		})
+	}
+}
+
+func TestUnknownOptimizerIsValidationError(t *testing.T) {
+	r := llama2_7BCapacityRequest("").MemoryRequest
+	r.Optimizer = "AdamX"
+	_, err := CalculateMemoryRequirements(&r)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Errorf("error = %v, want a ValidationError", err)
+	}
+}
+
+func TestOffloadSummary(t *testing.T) {
+	tests := []struct {
+		name              string
+		optimizer, params string
+		want              OffloadSummary
+	}{
+		// Llama-2-7B in bf16 under ZeRO-3 on one GPU: 12.55 GB of weights, 25.10 GB each of
+		// fp32 gradients and master weights and 50.21 GB of AdamW moments. The gradients go
+		// down and the bf16 weights come back: 6 bytes × 6.74B over 25 GB/s. The 16.31 GB
+		// left on the GPU pays PyTorch's 1.5 GB and 5% overhead.
+		{"optimizer to cpu", calc.OffloadCPU, "", OffloadSummary{
+			GPUMemory: "18.63 GB", HostMemory: "100.41 GB", NVMeMemory: "0.00 B",
+			PCIeTrafficPerStep: "37.65 GB", PCIeTimePerStepMs: 6738415616 * 6 / 25e9 * 1000, NVMeTrafficPerStep: "0.00 B",
+		}},
+		// Master weights and moments sit on NVMe and are read and written every step.
+		{"optimizer to nvme", calc.OffloadNVMe, "", OffloadSummary{
+			GPUMemory: "18.63 GB", HostMemory: "25.10 GB", NVMeMemory: "75.31 GB",
+			PCIeTrafficPerStep: "37.65 GB", PCIeTimePerStepMs: 6738415616 * 6 / 25e9 * 1000, NVMeTrafficPerStep: "150.62 GB",
+		}},
+		// The weights leave too and are fetched for the forward and backward pass: 8 bytes × 6.74B.
+		// 3.76 GB stays on the GPU before overhead.
+		{"optimizer and params to cpu", calc.OffloadCPU, calc.OffloadCPU, OffloadSummary{
+			GPUMemory: "5.45 GB", HostMemory: "112.96 GB", NVMeMemory: "0.00 B",
+			PCIeTrafficPerStep: "50.21 GB", PCIeTimePerStepMs: 6738415616 * 8 / 25e9 * 1000, NVMeTrafficPerStep: "0.00 B",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := llama2_7BCapacityRequest("").MemoryRequest
+			r.TorchDtype, r.Optimizer = "bfloat16", "AdamW"
+			r.ShardingStrategy, r.DataParallelSize = calc.ShardZeRO3, 1
+			r.BatchSize, r.SequenceLength, r.Checkpointing = 1, 2048, calc.CheckpointFull
+			r.OffloadOptimizer, r.OffloadParams = tt.optimizer, tt.params
+			resp, err := CalculateMemoryRequirements(&r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			want.OptimizerTarget, want.ParamsTarget, want.PCIeBandwidthGBs = tt.optimizer, tt.params, 25
+			got := *resp.Offload
+			if math.Abs(got.PCIeTimePerStepMs-want.PCIeTimePerStepMs) > 1e-6 {
+				t.Errorf("pcie time = %.2f ms, want %.2f ms", got.PCIeTimePerStepMs, want.PCIeTimePerStepMs)
+			}
+			got.PCIeTimePerStepMs = want.PCIeTimePerStepMs
+			if got != want {
+				t.Errorf("offload = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSingleGPUOffload(t *testing.T) {
+	r := llama2_7BCapacityRequest("").MemoryRequest
+	r.TorchDtype, r.Optimizer = "bfloat16", "AdamW"
+	r.BatchSize, r.SequenceLength, r.Checkpointing = 1, 2048, calc.CheckpointFull
+	r.OffloadOptimizer = calc.OffloadCPU
+	resp, fp, err := calculate(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same state leaves the GPU as under ZeRO-3 on one rank, with no communication buffers.
+	if resp.Offload == nil || resp.Offload.HostMemory != "100.41 GB" {
+		t.Fatalf("offload = %+v, want 100.41 GB of host memory", resp.Offload)
+	}
+	if fp.Sharded.CommBuffers != 0 || fp.Sharded.OptimizerStates != 0 || fp.Sharded.Gradients != 0 {
+		t.Errorf("per-GPU footprint = %+v, want optimizer state, gradients and buffers off the GPU", fp.Sharded)
+	}
+	if resp.ShardingStrategy != "" {
+		t.Errorf("sharding strategy = %q, want none", resp.ShardingStrategy)
+	}
+}
+
+func TestOffloadHostMemoryLimit(t *testing.T) {
+	r := llama2_7BCapacityRequest("").MemoryRequest
+	r.TorchDtype, r.Optimizer = "bfloat16", "AdamW"
+	r.ShardingStrategy, r.DataParallelSize = calc.ShardZeRO3, 1
+	r.BatchSize, r.SequenceLength, r.Checkpointing = 1, 2048, calc.CheckpointFull
+	r.OffloadOptimizer = calc.OffloadCPU
+	// 100.41 GB of offloaded state needs more than 64 GB of host RAM per GPU.
+	r.HostMemoryPerGPUGB = 64
+	resp, err := CalculateMemoryRequirements(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.TrainingGPUs) != 0 {
+		t.Errorf("recommended %s with 64 GB of host RAM per GPU", resp.TrainingGPUs[0].GPU.Name)
+	}
+	r.HostMemoryPerGPUGB = 128
+	if resp, err = CalculateMemoryRequirements(&r); err != nil || len(resp.TrainingGPUs) == 0 {
+		t.Errorf("no GPU recommended with 128 GB of host RAM per GPU: %v", err)
+	}
+}
+
+func TestValidateOffload(t *testing.T) {
+	tests := []struct {
+		name              string
+		strategy          string
+		optimizer, params string
+		valid             bool
+	}{
+		{"zero1 optimizer to cpu", calc.ShardZeRO1, calc.OffloadCPU, "", true},
+		{"zero3 params to nvme", calc.ShardZeRO3, calc.OffloadNVMe, calc.OffloadNVMe, true},
+		{"single GPU optimizer to cpu", "", calc.OffloadCPU, "", true},
+		{"ddp optimizer to cpu", calc.ShardDDP, calc.OffloadCPU, "", true},
+		{"single GPU params", "", "", calc.OffloadCPU, false},
+		{"zero2 params", calc.ShardZeRO2, "", calc.OffloadCPU, false},
+		{"unknown target", calc.ShardZeRO3, "disk", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := llama2_7BCapacityRequest("").MemoryRequest
+			r.Optimizer = "AdamW"
+			r.ShardingStrategy = tt.strategy
+			r.OffloadOptimizer, r.OffloadParams = tt.optimizer, tt.params
+			if err := validateRequest(&r); (err == nil) != tt.valid {
+				t.Errorf("validateRequest = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestFrameworkOverheadIsPerDevice(t *testing.T) {
+	r := MemoryRequest{
+		ModelSize:         70,
+		VocabSize:         32000,
+		HiddenSize:        8192,
+		IntermediateSize:  28672,
+		NumHiddenLayers:   80,
+		NumAttentionHeads: 64,
+		NumKeyValueHeads:  8,
+		SequenceLength:    4096,
+		BatchSize:         1,
+		TorchDtype:        "bfloat16",
+		Optimizer:         "AdamW",
+		FlashAttention:    true,
+	}
+	resp, fp, err := calculate(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The aggregate is model memory only; each GPU pays its own overhead.
+	if got, want := resp.TrainingMemory, calc.FormatMemory(fp.Training.Total()); got != want {
+		t.Errorf("training memory = %s, want %s without overhead", got, want)
+	}
+	numGPUs := resp.TrainingGPUs[0].NumGPUs
+	if numGPUs < 2 {
+		t.Fatalf("70B AdamW fits on %d GPU", numGPUs)
+	}
+	pytorch := calc.FrameworkOverheads[calc.FrameworkPyTorch]
+	want := pytorch.Fixed + fp.Training.Total()/float64(numGPUs)*pytorch.Fragmentation
+	if got := resp.TrainingFrameworkOverhead; got != calc.FormatMemory(want) {
+		t.Errorf("training framework overhead = %s, want %s per GPU", got, calc.FormatMemory(want))
+	}
+}
+
+func TestValidateLoRATargets(t *testing.T) {
+	// DeepSeek-V3's attention: MLA with a query bottleneck.
+	deepseek := MemoryRequest{
+		ModelSize:         671,
+		HiddenSize:        7168,
+		IntermediateSize:  18432,
+		NumHiddenLayers:   61,
+		NumAttentionHeads: 128,
+		KVLoRARank:        512,
+		QLoRARank:         1536,
+		QKRopeHeadDim:     64,
+		QKNopeHeadDim:     128,
+		VHeadDim:          128,
+		SequenceLength:    4096,
+		BatchSize:         1,
+		TorchDtype:        "bfloat16",
+		FineTuneMethod:    calc.FineTuneLoRA,
+		LoRARank:          16,
+	}
+	dense := llama2_7BCapacityRequest("").MemoryRequest
+	dense.FineTuneMethod, dense.LoRARank = calc.FineTuneLoRA, 16
+	tests := []struct {
+		name    string
+		req     MemoryRequest
+		targets []string
+		valid   bool
+	}{
+		{"MLA default targets", deepseek, nil, true},
+		{"MLA latent projections", deepseek, []string{"q_a_proj", "kv_b_proj"}, true},
+		{"MLA q_proj behind a query bottleneck", deepseek, []string{"q_proj"}, false},
+		{"MLA v_proj", deepseek, []string{"v_proj"}, false},
+		{"dense default targets", dense, nil, true},
+		{"dense q_a_proj", dense, []string{"q_a_proj"}, false},
+		{"dense kv_b_proj", dense, []string{"q_proj", "kv_b_proj"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.req
+			r.LoRATargetModules = tt.targets
+			if err := validateRequest(&r); (err == nil) != tt.valid {
+				t.Errorf("validateRequest = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestMLALoRADefaultTargets(t *testing.T) {
+	r := MemoryRequest{
+		ModelSize:         671,
+		HiddenSize:        7168,
+		IntermediateSize:  18432,
+		NumHiddenLayers:   61,
+		NumAttentionHeads: 128,
+		KVLoRARank:        512,
+		QLoRARank:         1536,
+		QKRopeHeadDim:     64,
+		QKNopeHeadDim:     128,
+		VHeadDim:          128,
+		SequenceLength:    4096,
+		BatchSize:         1,
+		TorchDtype:        "bfloat16",
+		Optimizer:         "AdamW",
+		FineTuneMethod:    calc.FineTuneLoRA,
+		LoRARank:          16,
+	}
+	_, fp, err := calculate(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp.Training.Gradients == 0 || fp.Training.Optimizer == 0 {
+		t.Errorf("default MLA targets train nothing: gradients %.0f, optimizer %.0f", fp.Training.Gradients, fp.Training.Optimizer)
+	}
+}
+
+func TestPreQuantizedKeepsPlainDtype(t *testing.T) {
+	r := llama2_7BCapacityRequest("").MemoryRequest
+	r.QuantizationConfig = &config.QuantizationConfig{QuantMethod: "gptq", Bits: 4, GroupSize: 128}
+	r.TorchDtype = "bfloat16"
+	want := calc.GetWeightFootprint(r.modelSpec(), r.TorchDtype)
+	// An nf4 torch_dtype must not quantize the embeddings, lm_head and norms GPTQ leaves out.
+	r.TorchDtype = "nf4"
+	if err := validateRequest(&r); err != nil {
+		t.Fatal(err)
+	}
+	if got := calc.GetWeightFootprint(r.modelSpec(), r.TorchDtype); got != want {
+		t.Errorf("weights = %.0f B, want %.0f B with 16-bit kept modules", got, want)
+	}
+}
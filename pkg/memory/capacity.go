@@ -0,0 +1,237 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/gpu"
+	"fmt"
+	"strings"
+)
+
+const (
+	SolveMaxBatch    = "max_batch"
+	SolveMaxSequence = "max_sequence"
+	SolveFrontier    = "frontier"
+
+	DefaultMaxBatchSize      = 65536
+	DefaultMaxSequenceLength = 1 << 20
+	frontierMinSequence      = 512
+)
+
+// SolveCapacity searches for the largest batch, sequence or frontier that fits, leaving req untouched.
+func SolveCapacity(req *CapacityRequest) (*CapacityResponse, error) {
+	r := *req
+	device, err := validateCapacity(&r)
+	if err != nil {
+		return nil, &ValidationError{err}
+	}
+	if err := validateRequest(&r.MemoryRequest); err != nil {
+		return nil, &ValidationError{err}
+	}
+
+	usable := float64(device.Memory) * (1 - r.Headroom) * 1024 * 1024 * 1024
+	resp := CapacityResponse{
+		GPU:                device.Name,
+		NumGPUs:            r.NumGPUs,
+		Headroom:           r.Headroom,
+		UsableMemoryPerGPU: calc.FormatMemory(usable),
+		Solve:              r.Solve,
+	}
+	inference, err := r.solve(false, usable)
+	if err != nil {
+		return nil, err
+	}
+	resp.Inference = inference
+	if r.Optimizer != "" {
+		training, err := r.solve(true, usable)
+		if err != nil {
+			return nil, err
+		}
+		resp.Training = &training
+	}
+	return &resp, nil
+}
+
+func (r *CapacityRequest) solve(training bool, usable float64) (CapacityResult, error) {
+	var result CapacityResult
+	fits := func(batch, seq int) (bool, error) {
+		bytes, err := r.perGPUBytes(training, batch, seq)
+		return bytes <= usable, err
+	}
+
+	switch r.Solve {
+	case SolveMaxBatch:
+		batch, err := maxFeasible(r.MaxBatchSize, func(n int) (bool, error) { return fits(n, r.SequenceLength) })
+		if err != nil || batch == 0 {
+			return result, err
+		}
+		result.MaxBatchSize = batch
+		result.MaxSequenceLength = r.SequenceLength
+		result.SearchLimited = batch == r.MaxBatchSize
+	case SolveMaxSequence:
+		seq, err := maxFeasible(r.MaxSequenceLength, func(n int) (bool, error) { return fits(r.BatchSize, n) })
+		if err != nil || seq == 0 {
+			return result, err
+		}
+		result.MaxBatchSize = r.BatchSize
+		result.MaxSequenceLength = seq
+		result.SearchLimited = seq == r.MaxSequenceLength
+	case SolveFrontier:
+		longest, err := maxFeasible(r.MaxSequenceLength, func(n int) (bool, error) { return fits(1, n) })
+		if err != nil || longest == 0 {
+			return result, err
+		}
+		for _, seq := range frontierSequences(longest) {
+			batch, err := maxFeasible(r.MaxBatchSize, func(n int) (bool, error) { return fits(n, seq) })
+			if err != nil {
+				return result, err
+			}
+			bytes, err := r.perGPUBytes(training, batch, seq)
+			if err != nil {
+				return result, err
+			}
+			result.Frontier = append(result.Frontier, FrontierPoint{
+				SequenceLength: seq,
+				BatchSize:      batch,
+				PerGPUMemory:   calc.FormatMemory(bytes),
+			})
+		}
+		result.MaxBatchSize = result.Frontier[0].BatchSize
+		result.MaxSequenceLength = longest
+		result.SearchLimited = longest == r.MaxSequenceLength || result.MaxBatchSize == r.MaxBatchSize
+	}
+
+	result.Fits = true
+	if r.Solve != SolveFrontier {
+		bytes, err := r.perGPUBytes(training, result.MaxBatchSize, result.MaxSequenceLength)
+		if err != nil {
+			return result, err
+		}
+		result.PerGPUMemory = calc.FormatMemory(bytes)
+	}
+	return result, nil
+}
+
+// perGPUBytes is the peak memory on one GPU. Without a layout or sharding every GPU
+// holds a full replica and its share of the batch, plus its own overhead.
+func (r *CapacityRequest) perGPUBytes(training bool, batch, seq int) (float64, error) {
+	req := r.MemoryRequest
+	req.BatchSize = batch
+	req.SequenceLength = seq
+	req.TrainingTokens = 0
+	if !training {
+		req.Optimizer = ""
+	}
+	sharded := training && (req.ShardingStrategy != "" || req.OffloadOptimizer != "")
+	if req.Parallelism == nil && !sharded {
+		req.BatchSize = (batch + r.NumGPUs - 1) / r.NumGPUs
+	}
+	resp, fp, err := calculate(&req)
+	if err != nil {
+		return 0, err
+	}
+	if resp.ParallelPlan != nil {
+		return resp.ParallelPlan.PerGPUBytes, nil
+	}
+	if sharded {
+		return fp.Sharded.PerGPU, nil
+	}
+	total := fp.Inference.Total()
+	if training {
+		total = fp.Training.Total()
+	}
+	return total + req.frameworkOverhead().PerGPU(total), nil
+}
+
+// maxFeasible returns the largest n in [1, limit] for which fits holds, or 0.
+func maxFeasible(limit int, fits func(n int) (bool, error)) (int, error) {
+	ok, err := fits(1)
+	if err != nil || !ok {
+		return 0, err
+	}
+	lo, hi := 1, 2
+	for hi <= limit {
+		ok, err := fits(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+	if hi > limit {
+		hi = limit + 1
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		ok, err := fits(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// frontierSequences samples powers of two up to and including the longest sequence.
+func frontierSequences(longest int) []int {
+	var seqs []int
+	for seq := frontierMinSequence; seq < longest; seq *= 2 {
+		seqs = append(seqs, seq)
+	}
+	return append(seqs, longest)
+}
+
+func validateCapacity(r *CapacityRequest) (gpu.GPUSpec, error) {
+	device, ok := gpu.FindGPU(r.GPU)
+	if !ok {
+		return device, fmt.Errorf("unknown GPU %q, valid GPUs: %s", r.GPU, strings.Join(gpu.GPUNames(), ", "))
+	}
+	if r.Headroom < 0 || r.Headroom >= 1 {
+		return device, fmt.Errorf("headroom must be at least 0 and below 1")
+	}
+	if r.NumGPUs < 0 || r.MaxBatchSize < 0 || r.MaxSequenceLength < 0 {
+		return device, fmt.Errorf("GPU count and search limits must not be negative")
+	}
+	if r.Parallelism != nil {
+		layoutGPUs := r.Parallelism.WithDefaults().GPUs()
+		if r.NumGPUs == 0 {
+			r.NumGPUs = layoutGPUs
+		}
+		if r.NumGPUs != layoutGPUs {
+			return device, fmt.Errorf("number of GPUs (%d) does not match the parallel layout (%d)", r.NumGPUs, layoutGPUs)
+		}
+	}
+	if r.NumGPUs == 0 {
+		r.NumGPUs = 1
+	}
+	if r.ShardingStrategy != "" && r.DataParallelSize == 0 {
+		r.DataParallelSize = r.NumGPUs
+	}
+	if r.MaxBatchSize == 0 {
+		r.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if r.MaxSequenceLength == 0 {
+		r.MaxSequenceLength = DefaultMaxSequenceLength
+	}
+
+	switch r.Solve {
+	case "":
+		r.Solve = SolveMaxBatch
+		fallthrough
+	case SolveMaxBatch:
+		r.BatchSize = 1
+	case SolveMaxSequence:
+		r.SequenceLength = 1
+	case SolveFrontier:
+		r.BatchSize = 1
+		r.SequenceLength = 1
+	default:
+		return device, fmt.Errorf("invalid solve target %q, valid targets: %s, %s, %s", r.Solve, SolveMaxBatch, SolveMaxSequence, SolveFrontier)
+	}
+	return device, nil
+}
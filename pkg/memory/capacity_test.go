@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaxFeasible(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		threshold int
+		want      int
+	}{
+		{"nothing fits", 1024, 0, 0},
+		{"only one fits", 1024, 1, 1},
+		{"power of two", 1024, 256, 256},
+		{"between powers of two", 1024, 37, 37},
+		{"just below the limit", 1000, 999, 999},
+		{"limit fits", 1000, 1000, 1000},
+		{"capped at a limit that is not a power of two", 1000, 5000, 1000},
+		{"capped at a power of two", 1024, 1 << 20, 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := 0
+			got, err := maxFeasible(tt.limit, func(n int) (bool, error) {
+				probes++
+				if n < 1 || n > tt.limit {
+					t.Fatalf("probed %d outside [1, %d]", n, tt.limit)
+				}
+				return n <= tt.threshold, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("maxFeasible = %d, want %d", got, tt.want)
+			}
+			// A doubling probe and a bisection each take at most log2(limit) + 1 steps.
+			if probes > 2*11+1 {
+				t.Errorf("took %d probes", probes)
+			}
+		})
+	}
+}
+
+func llama2_7BCapacityRequest(solve string) CapacityRequest {
+	return CapacityRequest{
+		MemoryRequest: MemoryRequest{
+			ModelSize:         7,
+			VocabSize:         32000,
+			HiddenSize:        4096,
+			IntermediateSize:  11008,
+			NumHiddenLayers:   32,
+			NumAttentionHeads: 32,
+			NumKeyValueHeads:  32,
+			SequenceLength:    4096,
+			BatchSize:         8,
+			TorchDtype:        "float16",
+			FlashAttention:    true,
+		},
+		GPU:   "NVIDIA A100-80GB",
+		Solve: solve,
+	}
+}
+
+func TestSolveCapacityBoundary(t *testing.T) {
+	r := llama2_7BCapacityRequest(SolveMaxBatch)
+	resp, err := SolveCapacity(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := resp.Inference.MaxBatchSize
+	if batch == 0 || resp.Inference.SearchLimited {
+		t.Fatalf("max batch = %d, search limited %v", batch, resp.Inference.SearchLimited)
+	}
+	// Llama-2-7B in fp16: 12.55 GiB of weights, then 2 GiB of KV cache and
+	// 0.53 GiB of prefill activations per 4,096-token sequence:
+	// (80 − 12.55) / 2.53 ≈ 26.6.
+	if batch != 26 {
+		t.Errorf("max batch = %d, want 26", batch)
+	}
+
+	normalised := r
+	if _, err := validateCapacity(&normalised); err != nil {
+		t.Fatal(err)
+	}
+	usable := 80.0 * 1024 * 1024 * 1024
+	for n, wantFit := range map[int]bool{batch: true, batch + 1: false} {
+		bytes, err := normalised.perGPUBytes(false, n, r.SequenceLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fits := bytes <= usable; fits != wantFit {
+			t.Errorf("batch %d: fits = %v, want %v", n, fits, wantFit)
+		}
+	}
+}
+
+func TestSolveCapacityLeavesRequestUnchanged(t *testing.T) {
+	for _, solve := range []string{"", SolveMaxBatch, SolveMaxSequence, SolveFrontier} {
+		r := llama2_7BCapacityRequest(solve)
+		before := r
+		if _, err := SolveCapacity(&r); err != nil {
+			t.Fatalf("%q: %v", solve, err)
+		}
+		if !reflect.DeepEqual(r, before) {
+			t.Errorf("%q: request changed from %+v to %+v", solve, before, r)
+		}
+	}
+}
+
+func TestSolveCapacityReplicatesUnshardedModel(t *testing.T) {
+	r := llama2_7BCapacityRequest(SolveMaxBatch)
+	r.NumGPUs = 2
+	resp, err := SolveCapacity(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each GPU keeps its own copy of the weights and 26 of the sequences, so
+	// two GPUs hold twice the batch of one rather than pooling their memory.
+	if got := resp.Inference.MaxBatchSize; got != 52 {
+		t.Errorf("max batch on 2 GPUs = %d, want 52", got)
+	}
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"fmt"
+	"sort"
+)
+
+func buildParallelPlan(r *MemoryRequest, layout calc.ParallelLayout) (ParallelPlan, calc.TrainingFootprint) {
+	spec := r.modelSpec()
+	training := r.Optimizer != ""
+	layout = layout.WithDefaults()
+	footprint := calc.GetParallelFootprint(spec, r.TorchDtype, r.BatchSize, r.SequenceLength, layout, r.trainingOptions(), training)
+	breakdown := footprint.Format()
+	if !training {
+		for _, key := range []string{"gradients", "optimizer_states", "master_weights", "communication_buffers"} {
+			delete(breakdown, key)
+		}
+	}
+	return ParallelPlan{
+		Layout:         layout,
+		NumGPUs:        layout.GPUs(),
+		LayersPerStage: layout.LayersPerStage(r.NumHiddenLayers),
+		PerGPUMemory:   calc.FormatMemory(footprint.PerGPU),
+		PerGPUBytes:    footprint.PerGPU,
+		Breakdown:      breakdown,
+	}, footprint
+}
+
+// PlanParallelism ranks every layout of the GPUs by per-GPU memory without modifying req.
+func PlanParallelism(req *ParallelismRequest) (*ParallelismResponse, error) {
+	r := *req
+	if r.Parallelism == nil {
+		r.Parallelism = &calc.ParallelLayout{}
+	}
+	if err := validateRequest(&r.MemoryRequest); err != nil {
+		return nil, &ValidationError{err}
+	}
+	if r.NumGPUs <= 0 {
+		return nil, &ValidationError{fmt.Errorf("number of GPUs must be positive")}
+	}
+	applyRequestDefaults(&r.MemoryRequest)
+
+	layouts := calc.EnumerateLayouts(r.modelSpec(), r.NumGPUs, *r.Parallelism)
+	if len(layouts) == 0 {
+		return nil, &ValidationError{fmt.Errorf("no valid parallel layout for %d GPUs", r.NumGPUs)}
+	}
+
+	resp := ParallelismResponse{
+		NumGPUs:  r.NumGPUs,
+		Training: r.Optimizer != "",
+	}
+	for _, layout := range layouts {
+		plan, _ := buildParallelPlan(&r.MemoryRequest, layout)
+		resp.Layouts = append(resp.Layouts, plan)
+	}
+	sort.SliceStable(resp.Layouts, func(i, j int) bool {
+		return resp.Layouts[i].PerGPUBytes < resp.Layouts[j].PerGPUBytes
+	})
+	return &resp, nil
+}
+
+func validateParallelism(req *MemoryRequest) error {
+	l := req.Parallelism
+	if l == nil {
+		return nil
+	}
+	if l.TensorParallel < 0 || l.PipelineParallel < 0 || l.DataParallel < 0 || l.ContextParallel < 0 || l.MicroBatches < 0 {
+		return fmt.Errorf("parallelism degrees must not be negative")
+	}
+	if l.TensorParallel > 0 && req.NumAttentionHeads%l.TensorParallel != 0 {
+		return fmt.Errorf("number of attention heads (%d) must be divisible by tensor parallel size (%d)", req.NumAttentionHeads, l.TensorParallel)
+	}
+	if l.PipelineParallel > req.NumHiddenLayers {
+		return fmt.Errorf("pipeline parallel size (%d) exceeds number of layers (%d)", l.PipelineParallel, req.NumHiddenLayers)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/config"
+	"errors"
+	"testing"
+)
+
+func TestPlanParallelismRejectsImpossibleLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		numGPUs int
+		layout  *calc.ParallelLayout
+	}{
+		{"no GPUs", 0, nil},
+		// Context parallelism of 2 cannot split 7 GPUs.
+		{"CP does not divide the GPUs", 7, &calc.ParallelLayout{ContextParallel: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ParallelismRequest{
+				MemoryRequest: MemoryRequest{
+					ModelSize:         7,
+					HiddenSize:        4096,
+					NumHiddenLayers:   32,
+					NumAttentionHeads: 32,
+					SequenceLength:    2048,
+					BatchSize:         1,
+					TorchDtype:        "bfloat16",
+					Parallelism:       tt.layout,
+				},
+				NumGPUs: tt.numGPUs,
+			}
+			_, err := PlanParallelism(&r)
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Errorf("PlanParallelism error = %v, want a ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestPlanParallelism(t *testing.T) {
+	r := ParallelismRequest{
+		MemoryRequest: MemoryRequest{
+			ModelSize:         7,
+			HiddenSize:        4096,
+			NumHiddenLayers:   32,
+			NumAttentionHeads: 32,
+			SequenceLength:    2048,
+			BatchSize:         1,
+			TorchDtype:        "bfloat16",
+		},
+		NumGPUs: 8,
+	}
+	if _, err := PlanParallelism(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.NumKeyValueHeads != 0 || r.Parallelism != nil || r.Framework != "" {
+		t.Errorf("PlanParallelism modified the request: %+v", r.MemoryRequest)
+	}
+	r.Encoder = &config.ComponentConfig{HiddenSize: 1024, IntermediateSize: 4096, NumHiddenLayers: 12, NumAttentionHeads: 16}
+	var invalid *ValidationError
+	if _, err := PlanParallelism(&r); !errors.As(err, &invalid) {
+		t.Errorf("PlanParallelism error = %v, want a ValidationError for an encoder", err)
+	}
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/config"
+	"compute-gauge/pkg/gpu"
+	"fmt"
+	"strings"
+)
+
+// EstimateServingCapacity sizes a paged-attention engine's KV blocks without modifying req.
+func EstimateServingCapacity(req *ServingRequest) (*ServingResponse, error) {
+	r := *req
+	device, err := validateServing(&r)
+	if err != nil {
+		return nil, &ValidationError{err}
+	}
+	if err := validateRequest(&r.MemoryRequest); err != nil {
+		return nil, &ValidationError{err}
+	}
+	if r.Framework == "" {
+		r.Framework = calc.FrameworkVLLM
+	}
+	applyRequestDefaults(&r.MemoryRequest)
+	r.AvgPromptLength += r.imageTokens()
+
+	spec := r.modelSpec()
+	gpuMemory := float64(device.Memory) * float64(r.NumGPUs) * 1024 * 1024 * 1024
+	c := calc.GetServingCapacity(spec, r.TorchDtype, gpuMemory, calc.ServingOptions{
+		GPUMemoryUtilization: r.GPUMemoryUtilization,
+		BlockSize:            r.BlockSize,
+		AvgPromptLength:      r.AvgPromptLength,
+		AvgOutputLength:      r.AvgOutputLength,
+		MaxModelLen:          r.SequenceLength,
+		TensorParallel:       r.NumGPUs,
+		Activation:           r.activationOptions(),
+		KVCache:              r.kvCacheOptions(),
+		Overhead:             r.frameworkOverhead(),
+	})
+	if c.KVBudget <= 0 {
+		return nil, &ValidationError{fmt.Errorf("model weights, activations and framework overhead (%s) exceed %.0f%% of %d x %s", calc.FormatMemory(c.Weights+c.Activations+c.FrameworkOverhead), r.GPUMemoryUtilization*100, r.NumGPUs, device.Name)}
+	}
+	var ssmStatePerSequence string
+	if c.SSMStatePerSequence > 0 {
+		ssmStatePerSequence = calc.FormatMemory(c.SSMStatePerSequence)
+	}
+	var crossKVPerSequence string
+	if c.CrossKVPerSequence > 0 {
+		crossKVPerSequence = calc.FormatMemory(c.CrossKVPerSequence)
+	}
+	return &ServingResponse{
+		GPU:                    device.Name,
+		NumGPUs:                r.NumGPUs,
+		GPUMemoryUtilization:   r.GPUMemoryUtilization,
+		BlockSize:              r.BlockSize,
+		KVCacheDtype:           r.KVCacheDtype,
+		AttentionVariant:       config.DetectAttentionVariant(r.NumAttentionHeads, r.NumKeyValueHeads, r.KVLoRARank),
+		ModelWeights:           calc.FormatMemory(c.Weights),
+		WeightBitsPerParam:     calc.GetWeightFootprint(spec, r.TorchDtype) * 8 / spec.TotalParams(),
+		QuantizationScheme:     spec.QuantScheme,
+		ReservedActivations:    calc.FormatMemory(c.Activations),
+		Framework:              r.Framework,
+		FrameworkOverhead:      calc.FormatMemory(c.FrameworkOverhead),
+		KVCacheBudget:          calc.FormatMemory(c.KVBudget),
+		KVBlockMemory:          calc.FormatMemory(c.KVBytesPerBlock),
+		SSMStatePerSequence:    ssmStatePerSequence,
+		CrossKVPerSequence:     crossKVPerSequence,
+		NumKVBlocks:            c.NumBlocks,
+		BlocksPerSequence:      c.BlocksPerSequence,
+		MaxConcurrentSequences: c.MaxConcurrentSequences,
+		WastedTokensPerSeq:     c.WastedTokensPerSeq,
+		WastePercent:           c.WasteFraction * 100,
+		WastedKVMemory:         calc.FormatMemory(c.WastedKV),
+	}, nil
+}
+
+func validateServing(r *ServingRequest) (gpu.GPUSpec, error) {
+	device, ok := gpu.FindGPU(r.GPU)
+	if !ok {
+		return device, fmt.Errorf("unknown GPU %q, valid GPUs: %s", r.GPU, strings.Join(gpu.GPUNames(), ", "))
+	}
+	if r.NumGPUs < 0 || r.BlockSize < 0 || r.AvgOutputLength < 0 {
+		return device, fmt.Errorf("GPU count, block size and output length must not be negative")
+	}
+	if r.GPUMemoryUtilization < 0 || r.GPUMemoryUtilization > 1 {
+		return device, fmt.Errorf("gpu memory utilization must be between 0 and 1")
+	}
+	if r.AvgPromptLength <= 0 {
+		return device, fmt.Errorf("average prompt length must be positive")
+	}
+	if r.SequenceLength > 0 && r.AvgPromptLength+r.AvgOutputLength > r.SequenceLength {
+		return device, fmt.Errorf("average prompt plus output length (%d) exceeds sequence length (%d)", r.AvgPromptLength+r.AvgOutputLength, r.SequenceLength)
+	}
+	if r.NumGPUs == 0 {
+		r.NumGPUs = 1
+	}
+	if r.GPUMemoryUtilization == 0 {
+		r.GPUMemoryUtilization = calc.DefaultGPUMemoryUtilization
+	}
+	if r.BlockSize == 0 {
+		r.BlockSize = calc.DefaultKVBlockSize
+	}
+	if r.BatchSize == 0 {
+		r.BatchSize = 1
+	}
+	return device, nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func llama3_8BServingRequest() ServingRequest {
+	return ServingRequest{
+		MemoryRequest: MemoryRequest{
+			ModelSize:         8,
+			VocabSize:         128256,
+			HiddenSize:        4096,
+			IntermediateSize:  14336,
+			NumHiddenLayers:   32,
+			NumAttentionHeads: 32,
+			NumKeyValueHeads:  8,
+			SequenceLength:    8192,
+			TorchDtype:        "bfloat16",
+			FlashAttention:    true,
+		},
+		GPU:             "NVIDIA A100-80GB",
+		AvgPromptLength: 1000,
+		AvgOutputLength: 250,
+	}
+}
+
+func TestEstimateServingCapacityIsRepeatable(t *testing.T) {
+	r := llama3_8BServingRequest()
+	before := r
+	first, err := EstimateServingCapacity(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, before) {
+		t.Errorf("request changed from %+v to %+v", before, r)
+	}
+	second, err := EstimateServingCapacity(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("second call returned %+v, first %+v", second, first)
+	}
+}
+
+func TestEstimateServingCapacityValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *ServingRequest)
+	}{
+		{"unknown GPU", func(r *ServingRequest) { r.GPU = "TPU" }},
+		{"prompt longer than the context", func(r *ServingRequest) { r.AvgPromptLength = 9000 }},
+		// Llama-3-70B's 141 GB of bf16 weights cannot load on one 80 GB GPU.
+		{"weights exceed the budget", func(r *ServingRequest) {
+			r.ModelSize = 70
+			r.HiddenSize = 8192
+			r.IntermediateSize = 28672
+			r.NumHiddenLayers = 80
+			r.NumAttentionHeads = 64
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := llama3_8BServingRequest()
+			tt.modify(&r)
+			_, err := EstimateServingCapacity(&r)
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Errorf("error = %v, want a ValidationError", err)
+			}
+		})
+	}
+}
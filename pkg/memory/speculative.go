@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"compute-gauge/pkg/calc"
+	"compute-gauge/pkg/config"
+	"compute-gauge/pkg/gpu"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EstimateSpeculativeDecoding sizes a target and a loaded draft model on one tensor-parallel group and estimates the speedup.
+func EstimateSpeculativeDecoding(req *SpeculativeRequest, models map[string]config.ModelConfig) (*SpeculativeResponse, error) {
+	r := *req
+	device, draftConfig, err := validateSpeculative(&r, models)
+	if err != nil {
+		return nil, &ValidationError{err}
+	}
+	if err := validateRequest(&r.MemoryRequest); err != nil {
+		return nil, &ValidationError{err}
+	}
+	draft := r.draftRequest(draftConfig)
+	if err := validateRequest(&draft); err != nil {
+		return nil, &ValidationError{fmt.Errorf("draft model %s: %w", r.DraftModel, err)}
+	}
+	if r.Framework == "" {
+		r.Framework = calc.FrameworkVLLM
+	}
+	applyRequestDefaults(&r.MemoryRequest)
+	draft.Framework = r.Framework
+	applyRequestDefaults(&draft)
+
+	spec := r.modelSpec()
+	draftSpec := draft.modelSpec()
+	inference, _ := calc.CalculateInferenceMemory(spec, r.TorchDtype, r.BatchSize, r.SequenceLength, r.activationOptions(), r.kvCacheOptions())
+	target := inference.Total()
+	footprint := calc.GetSpeculativeFootprint(spec, draftSpec, r.TorchDtype, r.BatchSize, r.SequenceLength, r.activationOptions(), r.kvCacheOptions(), calc.SpeculativeOptions{
+		Lookahead:      r.Lookahead,
+		DraftPrecision: draft.TorchDtype,
+		DraftKVCache:   draft.kvCacheOptions(),
+	})
+	perGPU := (target + footprint.Total()) / float64(r.NumGPUs)
+	overhead := r.frameworkOverhead().PerGPU(perGPU)
+	perGPU += overhead
+
+	resp := SpeculativeResponse{
+		GPU:               device.Name,
+		NumGPUs:           r.NumGPUs,
+		DraftModel:        r.DraftModel,
+		Lookahead:         r.Lookahead,
+		AcceptanceRate:    r.AcceptanceRate,
+		TargetMemory:      calc.FormatMemory(target),
+		SpeculativeMemory: footprint.Format(),
+		Framework:         r.Framework,
+		FrameworkOverhead: calc.FormatMemory(overhead),
+		CombinedMemory:    calc.FormatMemory(perGPU * float64(r.NumGPUs)),
+		PerGPUMemory:      calc.FormatMemory(perGPU),
+		Fits:              perGPU <= float64(device.Memory)*1024*1024*1024,
+		Performance: gpu.EstimateSpeculative(device, r.NumGPUs, r.inferenceWorkload(), draft.inferenceWorkload(),
+			calc.GetDecodeWeightBytes(spec, r.TorchDtype, r.BatchSize*(r.Lookahead+1)), r.Lookahead, calc.GetExpectedTokens(r.AcceptanceRate, r.Lookahead)),
+	}
+	if draft.VocabSize != r.VocabSize {
+		resp.Warning = fmt.Sprintf("draft vocabulary (%d) differs from the target's (%d); both models must share a tokenizer", draft.VocabSize, r.VocabSize)
+	}
+	return &resp, nil
+}
+
+// draftRequest describes the draft model with the target's workload, at the target's precision unless it sets its own.
+func (r *SpeculativeRequest) draftRequest(c config.ModelConfig) MemoryRequest {
+	draft := MemoryRequest{
+		ModelSize:            c.ModelSize,
+		VocabSize:            c.VocabSize,
+		HiddenSize:           c.HiddenSize,
+		IntermediateSize:     c.IntermediateSize,
+		NumHiddenLayers:      c.NumHiddenLayers,
+		NumAttentionHeads:    c.NumAttentionHeads,
+		NumKeyValueHeads:     c.NumKeyValueHeads,
+		HeadDim:              c.HeadDim,
+		HiddenAct:            c.HiddenAct,
+		TieWordEmbeddings:    c.TieWordEmbeddings,
+		AttentionBias:        c.AttentionBias,
+		MLPBias:              c.MLPBias,
+		NumLocalExperts:      c.NumLocalExperts,
+		NumExpertsPerTok:     c.NumExpertsPerTok,
+		SlidingWindow:        c.SlidingWindow,
+		UseSlidingWindow:     c.UseSlidingWindow,
+		MaxWindowLayers:      c.MaxWindowLayers,
+		SlidingWindowPattern: c.SlidingWindowPattern,
+		LayerTypes:           c.LayerTypes,
+		KVLoRARank:           c.KVLoRARank,
+		QLoRARank:            c.QLoRARank,
+		QKRopeHeadDim:        c.QKRopeHeadDim,
+		QKNopeHeadDim:        c.QKNopeHeadDim,
+		VHeadDim:             c.VHeadDim,
+		LayersBlockType:      c.LayersBlockType,
+		AttnLayerPeriod:      c.AttnLayerPeriod,
+		AttnLayerOffset:      c.AttnLayerOffset,
+		ExpertLayerPeriod:    c.ExpertLayerPeriod,
+		ExpertLayerOffset:    c.ExpertLayerOffset,
+		MambaDState:          c.MambaDState,
+		MambaDConv:           c.MambaDConv,
+		MambaExpand:          c.MambaExpand,
+		MambaDTRank:          c.MambaDTRank,
+		TorchDtype:           c.Precision,
+		QuantizationConfig:   c.Quantization,
+		SequenceLength:       r.SequenceLength,
+		BatchSize:            r.BatchSize,
+		PromptLength:         r.PromptLength,
+		KVCacheDtype:         r.KVCacheDtype,
+		KVOffloadFraction:    r.KVOffloadFraction,
+		FlashAttention:       r.FlashAttention,
+	}
+	if draft.TorchDtype == "" {
+		draft.TorchDtype = r.TorchDtype
+	}
+	return draft
+}
+
+func validateSpeculative(r *SpeculativeRequest, models map[string]config.ModelConfig) (gpu.GPUSpec, config.ModelConfig, error) {
+	device, ok := gpu.FindGPU(r.GPU)
+	if !ok {
+		return device, config.ModelConfig{}, fmt.Errorf("unknown GPU %q, valid GPUs: %s", r.GPU, strings.Join(gpu.GPUNames(), ", "))
+	}
+	draft, ok := models[r.DraftModel]
+	if !ok {
+		names := make([]string, 0, len(models))
+		for name := range models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return device, draft, fmt.Errorf("unknown draft model %q, valid models: %s", r.DraftModel, strings.Join(names, ", "))
+	}
+	if r.NumGPUs < 0 || r.Lookahead < 0 {
+		return device, draft, fmt.Errorf("GPU count and lookahead must not be negative")
+	}
+	if r.AcceptanceRate <= 0 || r.AcceptanceRate > 1 {
+		return device, draft, fmt.Errorf("acceptance rate must be above 0 and at most 1")
+	}
+	if r.Parallelism != nil {
+		return device, draft, fmt.Errorf("speculative decoding does not support a parallelism layout, use num_gpus")
+	}
+	if r.NumGPUs == 0 {
+		r.NumGPUs = 1
+	}
+	if r.Lookahead == 0 {
+		r.Lookahead = calc.DefaultLookahead
+	}
+	return device, draft, nil
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"compute-gauge/pkg/config"
+	"reflect"
+	"testing"
+)
+
+func TestEstimateSpeculativeDecodingIsRepeatable(t *testing.T) {
+	models := map[string]config.ModelConfig{
+		"llama-3.2-1b": {
+			Name:              "llama-3.2-1b",
+			ModelSize:         1.24,
+			VocabSize:         128256,
+			HiddenSize:        2048,
+			IntermediateSize:  8192,
+			NumHiddenLayers:   16,
+			NumAttentionHeads: 32,
+			NumKeyValueHeads:  8,
+			TieWordEmbeddings: true,
+		},
+	}
+	// Framework, lookahead and GPU count are left for the estimate to default.
+	r := SpeculativeRequest{
+		MemoryRequest:  llama3_8BServingRequest().MemoryRequest,
+		DraftModel:     "llama-3.2-1b",
+		AcceptanceRate: 0.7,
+		GPU:            "NVIDIA A100-80GB",
+	}
+	r.BatchSize = 1
+	before := r
+	first, err := EstimateSpeculativeDecoding(&r, models)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, before) {
+		t.Errorf("request changed from %+v to %+v", before, r)
+	}
+	second, err := EstimateSpeculativeDecoding(&r, models)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("second call returned %+v, first %+v", second, first)
+	}
+}
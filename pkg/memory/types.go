@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"compute-gauge/pkg/calc"
 	"compute-gauge/pkg/config"
 	"compute-gauge/pkg/gpu"
 	"encoding/json"
@@ -8,8 +9,13 @@ import (
 )
 
 type PageData struct {
-	Models    map[string]config.ModelConfig
-	DataTypes []string
+	Models        map[string]config.ModelConfig
+	DataTypes     []string
+	StateTypes    []string
+	KVCacheDtypes []string
+	Optimizers    []string
+	Frameworks    []string
+	GPUs          []string
 }
 
 func (p PageData) ModelsJSON() template.JS {
@@ -21,27 +27,323 @@ func (p PageData) ModelsJSON() template.JS {
 }
 
 type MemoryResponse struct {
-	ModelWeights     string                  `json:"model_weights"`
-	KVCache          string                  `json:"kv_cache"`
-	ActivationMemory string                  `json:"activation_memory"`
-	OptimizerMemory  string                  `json:"optimizer_memory,omitempty"`
-	GradientsMemory  string                  `json:"gradients_memory,omitempty"`
-	InferenceMemory  string                  `json:"inference_memory"`
-	TrainingMemory   string                  `json:"training_memory,omitempty"`
-	InferenceGPUs    []gpu.GPURecommendation `json:"inference_gpus"`
-	TrainingGPUs     []gpu.GPURecommendation `json:"training_gpus,omitempty"`
-	TotalParams      float64                 `json:"total_params"`
-	HiddenSize       int                     `json:"hidden_size"`
-	SequenceLength   int                     `json:"sequence_length"`
+	ModelWeights                string                      `json:"model_weights"`
+	WeightBitsPerParam          float64                     `json:"weight_bits_per_param"`
+	QuantizationScheme          string                      `json:"quantization_scheme,omitempty"`
+	KVCache                     string                      `json:"kv_cache"`
+	KVCacheDtype                string                      `json:"kv_cache_dtype"`
+	AttentionVariant            string                      `json:"attention_variant"`
+	KVCacheGPU                  string                      `json:"kv_cache_gpu"`
+	KVCacheHost                 string                      `json:"kv_cache_host"`
+	KVCacheBreakdown            map[string]string           `json:"kv_cache_breakdown,omitempty"`
+	SSMState                    string                      `json:"ssm_state,omitempty"`
+	ConvState                   string                      `json:"conv_state,omitempty"`
+	LayerMix                    map[string]int              `json:"layer_mix,omitempty"`
+	Components                  map[string]ComponentSummary `json:"components,omitempty"`
+	ComponentWeights            string                      `json:"component_weights,omitempty"`
+	CrossAttentionKV            string                      `json:"cross_attention_kv,omitempty"`
+	ImageTokens                 int                         `json:"image_tokens,omitempty"`
+	ActivationMemory            string                      `json:"activation_memory"`
+	ActivationBreakdown         map[string]string           `json:"activation_breakdown"`
+	TrainingActivationMemory    string                      `json:"training_activation_memory,omitempty"`
+	TrainingActivationBreakdown map[string]string           `json:"training_activation_breakdown,omitempty"`
+	ActivationSavings           map[string]string           `json:"activation_savings,omitempty"`
+	ExpertWeights               string                      `json:"expert_weights,omitempty"`
+	ExpertShare                 float64                     `json:"expert_share,omitempty"`
+	RouterMemory                string                      `json:"router_memory,omitempty"`
+	OptimizerMemory             string                      `json:"optimizer_memory,omitempty"`
+	OptimizerPaged              bool                        `json:"optimizer_paged,omitempty"`
+	GradientsMemory             string                      `json:"gradients_memory,omitempty"`
+	MasterWeights               string                      `json:"master_weights,omitempty"`
+	PrecisionPolicy             *calc.PrecisionPolicy       `json:"precision_policy,omitempty"`
+	BytesPerParam               float64                     `json:"bytes_per_param,omitempty"`
+	BaseWeights                 string                      `json:"base_weights,omitempty"`
+	AdapterWeights              string                      `json:"adapter_weights,omitempty"`
+	TrainableParams             float64                     `json:"trainable_params,omitempty"`
+	FineTuneMethod              string                      `json:"finetune_method,omitempty"`
+	Framework                   string                      `json:"framework"`
+	FrameworkOverhead           string                      `json:"framework_overhead"`
+	TrainingFrameworkOverhead   string                      `json:"training_framework_overhead,omitempty"`
+	InferenceMemory             string                      `json:"inference_memory"`
+	TrainingMemory              string                      `json:"training_memory,omitempty"`
+	ShardingStrategy            string                      `json:"sharding_strategy,omitempty"`
+	DataParallelSize            int                         `json:"data_parallel_size,omitempty"`
+	PerGPUMemory                string                      `json:"per_gpu_memory,omitempty"`
+	PerGPUBreakdown             map[string]string           `json:"per_gpu_breakdown,omitempty"`
+	Offload                     *OffloadSummary             `json:"offload,omitempty"`
+	ParallelPlan                *ParallelPlan               `json:"parallel_plan,omitempty"`
+	InferenceGPUs               []gpu.GPURecommendation     `json:"inference_gpus"`
+	TrainingGPUs                []gpu.GPURecommendation     `json:"training_gpus,omitempty"`
+	TrainingEstimate            *gpu.TrainingEstimate       `json:"training_estimate,omitempty"`
+	TotalParams                 float64                     `json:"total_params"`
+	DeclaredParams              float64                     `json:"declared_params"`
+	ParamBreakdown              *calc.ParamBreakdown        `json:"param_breakdown,omitempty"`
+	ParamGap                    float64                     `json:"param_gap"`
+	ParamWarning                string                      `json:"param_warning,omitempty"`
+	ActiveParams                float64                     `json:"active_params"`
+	FlopsPerToken               float64                     `json:"flops_per_token"`
+	HiddenSize                  int                         `json:"hidden_size"`
+	NumKeyValueHeads            int                         `json:"num_key_value_heads"`
+	HeadDim                     int                         `json:"head_dim"`
+	SequenceLength              int                         `json:"sequence_length"`
 }
 
 type MemoryRequest struct {
-	ModelSize         float64 `json:"model_size"`
-	HiddenSize        int     `json:"hidden_size"`
-	NumHiddenLayers   int     `json:"num_hidden_layers"`
-	NumAttentionHeads int     `json:"num_attention_heads"`
-	SequenceLength    int     `json:"sequence_length"`
-	BatchSize         int     `json:"batch_size"`
-	TorchDtype        string  `json:"torch_dtype"`
-	Optimizer         string  `json:"optimizer"`
+	ModelSize            float64                    `json:"model_size"`
+	VocabSize            int                        `json:"vocab_size"`
+	HiddenSize           int                        `json:"hidden_size"`
+	IntermediateSize     int                        `json:"intermediate_size"`
+	NumHiddenLayers      int                        `json:"num_hidden_layers"`
+	NumAttentionHeads    int                        `json:"num_attention_heads"`
+	NumKeyValueHeads     int                        `json:"num_key_value_heads"`
+	HeadDim              int                        `json:"head_dim,omitempty"`
+	HiddenAct            string                     `json:"hidden_act,omitempty"`
+	TieWordEmbeddings    bool                       `json:"tie_word_embeddings"`
+	AttentionBias        bool                       `json:"attention_bias"`
+	MLPBias              bool                       `json:"mlp_bias"`
+	NumLocalExperts      int                        `json:"num_local_experts,omitempty"`
+	NumExpertsPerTok     int                        `json:"num_experts_per_tok,omitempty"`
+	SlidingWindow        int                        `json:"sliding_window,omitempty"`
+	UseSlidingWindow     *bool                      `json:"use_sliding_window,omitempty"`
+	MaxWindowLayers      int                        `json:"max_window_layers,omitempty"`
+	SlidingWindowPattern int                        `json:"sliding_window_pattern,omitempty"`
+	LayerTypes           []string                   `json:"layer_types,omitempty"`
+	KVLoRARank           int                        `json:"kv_lora_rank,omitempty"`
+	QLoRARank            int                        `json:"q_lora_rank,omitempty"`
+	QKRopeHeadDim        int                        `json:"qk_rope_head_dim,omitempty"`
+	QKNopeHeadDim        int                        `json:"qk_nope_head_dim,omitempty"`
+	VHeadDim             int                        `json:"v_head_dim,omitempty"`
+	LayersBlockType      []string                   `json:"layers_block_type,omitempty"`
+	LayersFFNType        []string                   `json:"layers_ffn_type,omitempty"`
+	AttnLayerPeriod      int                        `json:"attn_layer_period,omitempty"`
+	AttnLayerOffset      int                        `json:"attn_layer_offset,omitempty"`
+	ExpertLayerPeriod    int                        `json:"expert_layer_period,omitempty"`
+	ExpertLayerOffset    int                        `json:"expert_layer_offset,omitempty"`
+	MambaDState          int                        `json:"mamba_d_state,omitempty"`
+	MambaDConv           int                        `json:"mamba_d_conv,omitempty"`
+	MambaExpand          int                        `json:"mamba_expand,omitempty"`
+	MambaDTRank          int                        `json:"mamba_dt_rank,omitempty"`
+	SequenceLength       int                        `json:"sequence_length"`
+	BatchSize            int                        `json:"batch_size"`
+	TorchDtype           string                     `json:"torch_dtype"`
+	QuantizationConfig   *config.QuantizationConfig `json:"quantization_config,omitempty"`
+	Encoder              *config.ComponentConfig    `json:"encoder,omitempty"`
+	EncoderSeqLength     int                        `json:"encoder_sequence_length,omitempty"`
+	VisionConfig         *config.ComponentConfig    `json:"vision_config,omitempty"`
+	NumImages            int                        `json:"num_images,omitempty"`
+	ImageSeqLength       int                        `json:"image_seq_length,omitempty"`
+	KVCacheDtype         string                     `json:"kv_cache_dtype,omitempty"`
+	KVOffloadFraction    float64                    `json:"kv_offload_fraction,omitempty"`
+	Optimizer            string                     `json:"optimizer"`
+	PrecisionPolicy      calc.PrecisionPolicy       `json:"precision_policy"`
+	ShardingStrategy     string                     `json:"sharding_strategy,omitempty"`
+	DataParallelSize     int                        `json:"data_parallel_size,omitempty"`
+	ShardGroupSize       int                        `json:"shard_group_size,omitempty"`
+	OffloadOptimizer     string                     `json:"offload_optimizer,omitempty"`
+	OffloadParams        string                     `json:"offload_params,omitempty"`
+	PCIeBandwidthGBs     float64                    `json:"pcie_bandwidth_gbs,omitempty"`
+	HostMemoryPerGPUGB   float64                    `json:"host_memory_per_gpu_gb,omitempty"`
+	Framework            string                     `json:"framework,omitempty"`
+	FrameworkOverheadGB  *float64                   `json:"framework_overhead_gb,omitempty"`
+	Fragmentation        *float64                   `json:"fragmentation,omitempty"`
+	Parallelism          *calc.ParallelLayout       `json:"parallelism,omitempty"`
+	PromptLength         int                        `json:"prompt_length,omitempty"`
+	TrainingTokens       float64                    `json:"training_tokens,omitempty"`
+	TrainingGPU          string                     `json:"training_gpu,omitempty"`
+	TrainingNumGPUs      int                        `json:"training_num_gpus,omitempty"`
+	MFU                  float64                    `json:"mfu,omitempty"`
+	GPUHourlyRate        float64                    `json:"gpu_hourly_rate,omitempty"`
+	TTFTSLOMs            float64                    `json:"ttft_slo_ms,omitempty"`
+	DecodeSLOTokens      float64                    `json:"decode_slo_tokens_per_sec,omitempty"`
+	TrainableParams      *float64                   `json:"trainable_params,omitempty"`
+	Checkpointing        string                     `json:"activation_checkpointing,omitempty"`
+	FlashAttention       bool                       `json:"flash_attention"`
+	FusedCrossEntropy    bool                       `json:"fused_cross_entropy"`
+	FineTuneMethod       string                     `json:"finetune_method,omitempty"`
+	LoRARank             int                        `json:"lora_rank,omitempty"`
+	LoRATargetModules    []string                   `json:"lora_target_modules,omitempty"`
+	LoRABasePrecision    string                     `json:"lora_base_precision,omitempty"`
+	// scalarHead is set by the alignment planner for reward and value models.
+	scalarHead bool
+}
+
+type ComponentSummary struct {
+	Params    float64 `json:"params"`
+	Weights   string  `json:"weights"`
+	Dtype     string  `json:"dtype"`
+	Tokens    int     `json:"tokens"`
+	Trainable bool    `json:"trainable"`
+}
+
+type ParallelPlan struct {
+	Layout         calc.ParallelLayout `json:"layout"`
+	NumGPUs        int                 `json:"num_gpus"`
+	LayersPerStage int                 `json:"layers_per_stage"`
+	PerGPUMemory   string              `json:"per_gpu_memory"`
+	PerGPUBytes    float64             `json:"per_gpu_bytes"`
+	Breakdown      map[string]string   `json:"breakdown"`
+}
+
+type ParallelismRequest struct {
+	MemoryRequest
+	NumGPUs int `json:"num_gpus"`
+}
+
+type ParallelismResponse struct {
+	NumGPUs  int            `json:"num_gpus"`
+	Training bool           `json:"training"`
+	Layouts  []ParallelPlan `json:"layouts"`
+}
+
+type CapacityRequest struct {
+	MemoryRequest
+	GPU               string  `json:"gpu"`
+	NumGPUs           int     `json:"num_gpus"`
+	Headroom          float64 `json:"headroom"`
+	Solve             string  `json:"solve"`
+	MaxBatchSize      int     `json:"max_batch_size,omitempty"`
+	MaxSequenceLength int     `json:"max_sequence_length,omitempty"`
+}
+
+type FrontierPoint struct {
+	SequenceLength int    `json:"sequence_length"`
+	BatchSize      int    `json:"batch_size"`
+	PerGPUMemory   string `json:"per_gpu_memory"`
+}
+
+type CapacityResult struct {
+	Fits              bool            `json:"fits"`
+	MaxBatchSize      int             `json:"max_batch_size,omitempty"`
+	MaxSequenceLength int             `json:"max_sequence_length,omitempty"`
+	PerGPUMemory      string          `json:"per_gpu_memory,omitempty"`
+	SearchLimited     bool            `json:"search_limited,omitempty"`
+	Frontier          []FrontierPoint `json:"frontier,omitempty"`
+}
+
+type CapacityResponse struct {
+	GPU                string          `json:"gpu"`
+	NumGPUs            int             `json:"num_gpus"`
+	Headroom           float64         `json:"headroom"`
+	UsableMemoryPerGPU string          `json:"usable_memory_per_gpu"`
+	Solve              string          `json:"solve"`
+	Inference          CapacityResult  `json:"inference"`
+	Training           *CapacityResult `json:"training,omitempty"`
+}
+
+type ServingRequest struct {
+	MemoryRequest
+	GPU                  string  `json:"gpu"`
+	NumGPUs              int     `json:"num_gpus"`
+	GPUMemoryUtilization float64 `json:"gpu_memory_utilization,omitempty"`
+	BlockSize            int     `json:"block_size,omitempty"`
+	AvgPromptLength      int     `json:"avg_prompt_length"`
+	AvgOutputLength      int     `json:"avg_output_length"`
+}
+
+type ServingResponse struct {
+	GPU                    string  `json:"gpu"`
+	NumGPUs                int     `json:"num_gpus"`
+	GPUMemoryUtilization   float64 `json:"gpu_memory_utilization"`
+	BlockSize              int     `json:"block_size"`
+	KVCacheDtype           string  `json:"kv_cache_dtype"`
+	AttentionVariant       string  `json:"attention_variant"`
+	ModelWeights           string  `json:"model_weights"`
+	WeightBitsPerParam     float64 `json:"weight_bits_per_param"`
+	QuantizationScheme     string  `json:"quantization_scheme,omitempty"`
+	ReservedActivations    string  `json:"reserved_activations"`
+	Framework              string  `json:"framework"`
+	FrameworkOverhead      string  `json:"framework_overhead"`
+	KVCacheBudget          string  `json:"kv_cache_budget"`
+	KVBlockMemory          string  `json:"kv_block_memory"`
+	SSMStatePerSequence    string  `json:"ssm_state_per_sequence,omitempty"`
+	CrossKVPerSequence     string  `json:"cross_attention_kv_per_sequence,omitempty"`
+	NumKVBlocks            int     `json:"num_kv_blocks"`
+	BlocksPerSequence      int     `json:"blocks_per_sequence"`
+	MaxConcurrentSequences int     `json:"max_concurrent_sequences"`
+	WastedTokensPerSeq     float64 `json:"wasted_tokens_per_sequence"`
+	WastePercent           float64 `json:"waste_percent"`
+	WastedKVMemory         string  `json:"wasted_kv_memory"`
+}
+
+// AlignmentRole is one model of an alignment run. Its batch and sequence
+// length come from the AlignmentRequest; Trainable overrides the role's
+// default.
+type AlignmentRole struct {
+	MemoryRequest
+	Role      string `json:"role"`
+	Trainable *bool  `json:"trainable,omitempty"`
+}
+
+type AlignmentRequest struct {
+	Method           string          `json:"method"`
+	BatchSize        int             `json:"batch_size"`
+	PromptLength     int             `json:"prompt_length"`
+	GenerationLength int             `json:"generation_length"`
+	Roles            []AlignmentRole `json:"roles"`
+}
+
+type RoleFootprint struct {
+	Role            string  `json:"role"`
+	Precision       string  `json:"precision"`
+	Trainable       bool    `json:"trainable"`
+	Generates       bool    `json:"generates"`
+	Params          float64 `json:"params"`
+	Weights         string  `json:"weights"`
+	Activations     string  `json:"activations"`
+	TrainingState   string  `json:"training_state,omitempty"`
+	GenerationCache string  `json:"generation_cache,omitempty"`
+	Total           string  `json:"total"`
+	TotalBytes      float64 `json:"total_bytes"`
+}
+
+type AlignmentResponse struct {
+	Method            string                  `json:"method"`
+	BatchSize         int                     `json:"batch_size"`
+	SequenceLength    int                     `json:"sequence_length"`
+	Roles             []RoleFootprint         `json:"roles"`
+	FrameworkOverhead string                  `json:"framework_overhead"`
+	ColocatedMemory   string                  `json:"colocated_memory"`
+	DataParallelSize  int                     `json:"data_parallel_size,omitempty"`
+	LargestRoleMemory string                  `json:"largest_role_memory"`
+	ColocatedGPUs     []gpu.GPURecommendation `json:"colocated_gpus"`
+}
+
+// SpeculativeRequest pairs the target model at the top level with a loaded draft model.
+type SpeculativeRequest struct {
+	MemoryRequest
+	DraftModel     string  `json:"draft_model"`
+	Lookahead      int     `json:"lookahead,omitempty"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	GPU            string  `json:"gpu"`
+	NumGPUs        int     `json:"num_gpus,omitempty"`
+}
+
+type SpeculativeResponse struct {
+	GPU               string                  `json:"gpu"`
+	NumGPUs           int                     `json:"num_gpus"`
+	DraftModel        string                  `json:"draft_model"`
+	Lookahead         int                     `json:"lookahead"`
+	AcceptanceRate    float64                 `json:"acceptance_rate"`
+	TargetMemory      string                  `json:"target_memory"`
+	SpeculativeMemory map[string]string       `json:"speculative_memory"`
+	Framework         string                  `json:"framework"`
+	FrameworkOverhead string                  `json:"framework_overhead"`
+	CombinedMemory    string                  `json:"combined_memory"`
+	PerGPUMemory      string                  `json:"per_gpu_memory"`
+	Fits              bool                    `json:"fits"`
+	Performance       gpu.SpeculativeEstimate `json:"performance"`
+	Warning           string                  `json:"warning,omitempty"`
+}
+
+// OffloadSummary splits an offloaded job's per-GPU footprint across GPU, host and NVMe, with unoverlapped PCIe time per step.
+type OffloadSummary struct {
+	OptimizerTarget    string  `json:"optimizer,omitempty"`
+	ParamsTarget       string  `json:"params,omitempty"`
+	GPUMemory          string  `json:"gpu_memory"`
+	HostMemory         string  `json:"host_memory"`
+	NVMeMemory         string  `json:"nvme_memory"`
+	PCIeTrafficPerStep string  `json:"pcie_traffic_per_step"`
+	PCIeBandwidthGBs   float64 `json:"pcie_bandwidth_gbs"`
+	PCIeTimePerStepMs  float64 `json:"pcie_time_per_step_ms"`
+	NVMeTrafficPerStep string  `json:"nvme_traffic_per_step"`
 }
@@ -2,6 +2,15 @@ let modelConfigs = {};
 document.getElementById('optimizer').addEventListener('change', function(e) {
     const container = document.getElementById('trainable_params_container');
     container.style.display = e.target.value ? 'block' : 'none';
+    document.getElementById('finetune_container').style.display = e.target.value ? 'block' : 'none';
+    document.getElementById('precision_policy_container').style.display = e.target.value ? 'block' : 'none';
+    document.getElementById('sharding_container').style.display = e.target.value ? 'block' : 'none';
+    document.getElementById('training_budget_container').style.display = e.target.value ? 'block' : 'none';
+});
+document.getElementById('finetune_method').addEventListener('change', function(e) {
+    const isLoRA = e.target.value === 'lora' || e.target.value === 'qlora';
+    document.getElementById('lora_container').style.display = isLoRA ? 'block' : 'none';
+    document.getElementById('trainable_params_container').style.display = isLoRA ? 'none' : 'block';
 });
 function updateFormFields(modelName) {
     const form = document.getElementById('calculatorForm');
@@ -13,9 +22,14 @@ function updateFormFields(modelName) {
     console.log("Selected model config:", config);
     
     document.getElementById('model_size').value = config.model_size || '';
+    document.getElementById('vocab_size').value = config.vocab_size || '';
     document.getElementById('hidden_size').value = config.hidden_size || '';
     document.getElementById('num_hidden_layers').value = config.num_hidden_layers || '';
     document.getElementById('num_attention_heads').value = config.num_attention_heads || '';
+    document.getElementById('num_key_value_heads').value = config.num_key_value_heads || config.num_attention_heads || '';
+    document.getElementById('intermediate_size').value = config.intermediate_size || '';
+    document.getElementById('num_local_experts').value = config.num_local_experts || 0;
+    document.getElementById('num_experts_per_tok').value = config.num_experts_per_tok || 0;
     document.getElementById('sequence_length').value = config.max_position_embeddings || 4096;
     document.getElementById('batch_size').value = 1;
     
@@ -118,17 +132,81 @@ function displayResults(data) {
                 <div class="memory-breakdown">
                     <div class="memory-group">
                         <div class="memory-item">
-                            <span class="memory-label">Model Weights:</span>
+                            <span class="memory-label">Model Weights (${data.quantization_scheme ? data.quantization_scheme + ', ' : ''}${data.weight_bits_per_param.toFixed(2)} bits/param):</span>
                             <span class="memory-value">${data.model_weights}</span>
                         </div>
                         <div class="memory-item">
-                            <span class="memory-label">KV Cache:</span>
+                            <span class="memory-label">KV Cache (${data.attention_variant.toUpperCase()}, ${data.kv_cache_dtype}):</span>
                             <span class="memory-value">${data.kv_cache}</span>
                         </div>
+                        ${data.kv_cache_host !== '0.00 B' ? `
+                        <div class="memory-item">
+                            <span class="memory-label">KV Cache on GPU / Host:</span>
+                            <span class="memory-value">${data.kv_cache_gpu} / ${data.kv_cache_host}</span>
+                        </div>
+                        ` : ''}
+                        ${data.kv_cache_breakdown ? `
+                        <div class="memory-item">
+                            <span class="memory-label">KV per Layer (${data.kv_cache_breakdown.local_layers} local, window ${data.kv_cache_breakdown.sliding_window} / ${data.kv_cache_breakdown.global_layers} global):</span>
+                            <span class="memory-value">${data.kv_cache_breakdown.local_per_layer} / ${data.kv_cache_breakdown.global_per_layer}</span>
+                        </div>
+                        <div class="memory-item">
+                            <span class="memory-label">Saved by Sliding Window:</span>
+                            <span class="memory-value">${data.kv_cache_breakdown.saved_by_sliding_window}</span>
+                        </div>
+                        ` : ''}
+                        ${data.ssm_state ? `
+                        <div class="memory-item">
+                            <span class="memory-label">SSM / Conv State:</span>
+                            <span class="memory-value">${data.ssm_state} / ${data.conv_state}</span>
+                        </div>
+                        ` : ''}
+                        ${data.components ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Components (${Object.keys(data.components).join(', ')}):</span>
+                            <span class="memory-value">${data.component_weights}</span>
+                        </div>
+                        ` : ''}
+                        ${data.cross_attention_kv ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Cross-Attention KV:</span>
+                            <span class="memory-value">${data.cross_attention_kv}</span>
+                        </div>
+                        ` : ''}
+                        ${data.image_tokens ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Image Tokens:</span>
+                            <span class="memory-value">${data.image_tokens}</span>
+                        </div>
+                        ` : ''}
+                        ${data.layer_mix ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Layer Mix:</span>
+                            <span class="memory-value">${Object.entries(data.layer_mix).map(([block, count]) => `${count} ${block}`).join(', ')}</span>
+                        </div>
+                        ` : ''}
                         <div class="memory-item">
                             <span class="memory-label">Activation Memory:</span>
                             <span class="memory-value">${data.activation_memory}</span>
                         </div>
+                        ${data.expert_weights ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Expert Weights (${data.expert_share.toFixed(1)}%):</span>
+                            <span class="memory-value">${data.expert_weights}</span>
+                        </div>
+                        <div class="memory-item">
+                            <span class="memory-label">Router &amp; Dispatch:</span>
+                            <span class="memory-value">${data.router_memory}</span>
+                        </div>
+                        <div class="memory-item">
+                            <span class="memory-label">Active Parameters:</span>
+                            <span class="memory-value">${(data.active_params / 1e9).toFixed(2)}B</span>
+                        </div>
+                        ` : ''}
+                        <div class="memory-item">
+                            <span class="memory-label">Framework Overhead (${data.framework}):</span>
+                            <span class="memory-value">${data.framework_overhead}</span>
+                        </div>
                     </div>
                     <div class="memory-total">
                         <span class="memory-label">Total Inference Memory:</span>
@@ -153,7 +231,27 @@ function displayResults(data) {
                         </div>
                         <div class="memory-item">
                             <span class="memory-label">Activation Memory:</span>
-                            <span class="memory-value">${data.activation_memory}</span>
+                            <span class="memory-value">${data.training_activation_memory}</span>
+                        </div>
+                        ${data.training_activation_breakdown.logits ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Logits / Loss (included above):</span>
+                            <span class="memory-value">${data.training_activation_breakdown.logits} / ${data.training_activation_breakdown.loss}</span>
+                        </div>
+                        ` : ''}
+                        ${data.adapter_weights ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Base Weights (${data.finetune_method}):</span>
+                            <span class="memory-value">${data.base_weights}</span>
+                        </div>
+                        <div class="memory-item">
+                            <span class="memory-label">Adapter Weights (${(data.trainable_params / 1e6).toFixed(1)}M params):</span>
+                            <span class="memory-value">${data.adapter_weights}</span>
+                        </div>
+                        ` : ''}
+                        <div class="memory-item">
+                            <span class="memory-label">Master Weights (${data.precision_policy.master_weights}):</span>
+                            <span class="memory-value">${data.master_weights}</span>
                         </div>
                         <div class="memory-item">
                             <span class="memory-label">Optimizer Memory:</span>
@@ -163,15 +261,87 @@ function displayResults(data) {
                             <span class="memory-label">Gradients Memory:</span>
                             <span class="memory-value">${data.gradients_memory}</span>
                         </div>
+                        <div class="memory-item">
+                            <span class="memory-label">Framework Overhead (${data.framework}):</span>
+                            <span class="memory-value">${data.training_framework_overhead}</span>
+                        </div>
                     </div>
+                        ${data.activation_savings ? Object.entries(data.activation_savings).map(([option, saved]) => `
+                        <div class="memory-item">
+                            <span class="memory-label">Saved by ${option.replace(/_/g, ' ')}:</span>
+                            <span class="memory-value">${saved}</span>
+                        </div>
+                        `).join('') : ''}
                     <div class="memory-total">
-                        <span class="memory-label">Total Training Memory:</span>
+                        <span class="memory-label">Total Training Memory (${data.bytes_per_param} bytes/param):</span>
                         <span class="memory-value">${data.training_memory}</span>
                     </div>
+                    ${data.training_estimate ? `
+                    <div class="memory-group">
+                        <div class="memory-item">
+                            <span class="memory-label">Training FLOPs:</span>
+                            <span class="memory-value">${data.training_estimate.total_flops.toExponential(2)}</span>
+                        </div>
+                        <div class="memory-item">
+                            <span class="memory-label">Wall-Clock (${data.training_estimate.num_gpus}x ${data.training_estimate.gpu}):</span>
+                            <span class="memory-value">${data.training_estimate.wall_clock_days.toFixed(1)} days</span>
+                        </div>
+                        <div class="memory-item">
+                            <span class="memory-label">GPU-Hours:</span>
+                            <span class="memory-value">${Math.round(data.training_estimate.gpu_hours).toLocaleString()}</span>
+                        </div>
+                        ${data.training_estimate.rental_cost_usd ? `
+                        <div class="memory-item">
+                            <span class="memory-label">Rental Cost:</span>
+                            <span class="memory-value">$${Math.round(data.training_estimate.rental_cost_usd).toLocaleString()}</span>
+                        </div>
+                        ` : ''}
+                        <div class="memory-item">
+                            <span class="memory-label">Purchase Cost:</span>
+                            <span class="memory-value">$${Math.round(data.training_estimate.purchase_cost_usd).toLocaleString()}</span>
+                        </div>
+                    </div>
+                    ` : ''}
+                    ${data.per_gpu_memory ? `
+                    <div class="memory-total">
+                        <span class="memory-label">${data.sharding_strategy ? `Per-GPU Memory (${data.sharding_strategy}, ${data.data_parallel_size} ranks):` : 'Per-GPU Memory (offloaded):'}</span>
+                        <span class="memory-value">${data.per_gpu_memory}</span>
+                    </div>
+                    ` : ''}
+                    ${data.offload ? `
+                    <div class="memory-item">
+                        <span class="memory-label">Host Memory per GPU:</span>
+                        <span class="memory-value">${data.offload.host_memory}</span>
+                    </div>
+                    <div class="memory-item">
+                        <span class="memory-label">NVMe per GPU:</span>
+                        <span class="memory-value">${data.offload.nvme_memory}</span>
+                    </div>
+                    <div class="memory-item">
+                        <span class="memory-label">PCIe Traffic per Step:</span>
+                        <span class="memory-value">${data.offload.pcie_traffic_per_step} (${Math.round(data.offload.pcie_time_per_step_ms)} ms at ${data.offload.pcie_bandwidth_gbs} GB/s)</span>
+                    </div>
+                    ` : ''}
                 </div>
             </div>
             ` : ''}
         </div>
+        ${data.param_breakdown ? `
+        <div class="memory-section">
+            <h3>Parameter Breakdown (${(data.total_params / 1e9).toFixed(2)}B)</h3>
+            ${data.param_warning ? `<div class="error"><p>${data.param_warning}</p></div>` : ''}
+            <div class="memory-breakdown">
+                <div class="memory-group">
+                    ${['embeddings', 'attention', 'mlp', 'norms', 'lm_head'].map(key => `
+                    <div class="memory-item">
+                        <span class="memory-label">${key}:</span>
+                        <span class="memory-value">${(data.param_breakdown[key] / 1e6).toFixed(1)}M</span>
+                    </div>
+                    `).join('')}
+                </div>
+            </div>
+        </div>
+        ` : ''}
     `;
 
 
@@ -222,6 +392,20 @@ function createGPUCard(rec, type, isFirst) {
                     <span class="gpu-spec-label">Cost Per GPU</span>
                     <span class="gpu-spec-value">$${rec.gpu.price_usd.toFixed(2)}</span>
                 </div>
+                ${rec.performance ? `
+                <div class="gpu-spec">
+                    <span class="gpu-spec-label">Time to First Token</span>
+                    <span class="gpu-spec-value">${rec.performance.ttft_ms.toFixed(0)} ms (${rec.performance.prefill_bound}-bound)</span>
+                </div>
+                <div class="gpu-spec">
+                    <span class="gpu-spec-label">Decode Speed</span>
+                    <span class="gpu-spec-value">${rec.performance.decode_tokens_per_sec.toFixed(1)} tok/s per sequence</span>
+                </div>
+                <div class="gpu-spec">
+                    <span class="gpu-spec-label">Throughput</span>
+                    <span class="gpu-spec-value">${rec.performance.throughput_tokens_per_sec.toFixed(0)} tok/s</span>
+                </div>
+                ` : ''}
                 ${rec.num_gpus > 1 ? `
                 <div class="gpu-spec total-cost">
                     <span class="gpu-spec-label">Total Cost (${rec.num_gpus}x)</span>
@@ -251,13 +435,87 @@ document.querySelector('form').addEventListener('submit', async function(e) {
         
         // Convert form data to proper types
         data.model_size = parseFloat(formData.get('model_size') || '0');
+        data.vocab_size = parseInt(formData.get('vocab_size') || '0', 10);
         data.hidden_size = parseInt(formData.get('hidden_size') || '0', 10);
         data.num_hidden_layers = parseInt(formData.get('num_hidden_layers') || '0', 10);
         data.num_attention_heads = parseInt(formData.get('num_attention_heads') || '0', 10);
+        data.num_key_value_heads = parseInt(formData.get('num_key_value_heads') || '0', 10);
+        data.intermediate_size = parseInt(formData.get('intermediate_size') || '0', 10);
+        data.num_local_experts = parseInt(formData.get('num_local_experts') || '0', 10);
+        data.num_experts_per_tok = parseInt(formData.get('num_experts_per_tok') || '0', 10);
         data.sequence_length = parseInt(formData.get('sequence_length') || '0', 10);
         data.batch_size = parseInt(formData.get('batch_size') || '0', 10);
+        data.prompt_length = parseInt(formData.get('prompt_length') || '0', 10);
         data.torch_dtype = formData.get('torch_dtype') || 'float32';
+        data.kv_cache_dtype = formData.get('kv_cache_dtype') || '';
+        data.kv_offload_fraction = parseFloat(formData.get('kv_offload_fraction') || '0') / 100;
+        data.framework = formData.get('framework') || '';
+        data.activation_checkpointing = formData.get('activation_checkpointing') || 'none';
+        data.flash_attention = formData.get('flash_attention') === 'on';
+        data.fused_cross_entropy = formData.get('fused_cross_entropy') === 'on';
         data.optimizer = formData.get('optimizer') || '';
+        if (data.optimizer) {
+            data.trainable_params = parseFloat(formData.get('trainable_params_pct') || '100');
+            data.finetune_method = formData.get('finetune_method') || 'full';
+            data.training_tokens = parseFloat(formData.get('training_tokens') || '0') * 1e9;
+            data.training_gpu = formData.get('training_gpu') || '';
+            data.training_num_gpus = parseInt(formData.get('training_num_gpus') || '0', 10);
+            data.mfu = parseFloat(formData.get('mfu') || '0');
+            data.gpu_hourly_rate = parseFloat(formData.get('gpu_hourly_rate') || '0');
+            data.sharding_strategy = formData.get('sharding_strategy') || '';
+            if (data.sharding_strategy) {
+                data.data_parallel_size = parseInt(formData.get('data_parallel_size') || '1', 10);
+                if (data.sharding_strategy === 'hybrid') {
+                    data.shard_group_size = parseInt(formData.get('shard_group_size') || '0', 10);
+                }
+            }
+            data.offload_optimizer = formData.get('offload_optimizer') || '';
+            data.offload_params = formData.get('offload_params') || '';
+            data.precision_policy = {
+                master_weights: formData.get('master_weights_dtype') || '',
+                gradients: formData.get('gradients_dtype') || '',
+                optimizer_states: formData.get('optimizer_states_dtype') || ''
+            };
+            if (data.finetune_method !== 'full') {
+                data.lora_rank = parseInt(formData.get('lora_rank') || '0', 10);
+                data.lora_target_modules = (formData.get('lora_target_modules') || '')
+                    .split(',').map(m => m.trim()).filter(m => m);
+                data.lora_base_precision = formData.get('lora_base_precision') || 'nf4';
+            }
+        }
+
+        const selectedConfig = modelConfigs[formData.get('model_select')];
+        if (selectedConfig) {
+            data.head_dim = selectedConfig.head_dim || 0;
+            data.hidden_act = selectedConfig.hidden_act || '';
+            data.tie_word_embeddings = !!selectedConfig.tie_word_embeddings;
+            data.attention_bias = !!selectedConfig.attention_bias;
+            data.mlp_bias = !!selectedConfig.mlp_bias;
+            data.quantization_config = selectedConfig.quantization_config || null;
+            data.sliding_window = selectedConfig.sliding_window || 0;
+            data.use_sliding_window = selectedConfig.use_sliding_window;
+            data.max_window_layers = selectedConfig.max_window_layers || 0;
+            data.sliding_window_pattern = selectedConfig.sliding_window_pattern || 0;
+            data.layer_types = selectedConfig.layer_types || null;
+            data.kv_lora_rank = selectedConfig.kv_lora_rank || 0;
+            data.q_lora_rank = selectedConfig.q_lora_rank || 0;
+            data.qk_rope_head_dim = selectedConfig.qk_rope_head_dim || 0;
+            data.qk_nope_head_dim = selectedConfig.qk_nope_head_dim || 0;
+            data.v_head_dim = selectedConfig.v_head_dim || 0;
+            data.layers_block_type = selectedConfig.layers_block_type || null;
+            data.attn_layer_period = selectedConfig.attn_layer_period || 0;
+            data.attn_layer_offset = selectedConfig.attn_layer_offset || 0;
+            data.expert_layer_period = selectedConfig.expert_layer_period || 0;
+            data.expert_layer_offset = selectedConfig.expert_layer_offset || 0;
+            data.mamba_d_state = selectedConfig.mamba_d_state || 0;
+            data.mamba_d_conv = selectedConfig.mamba_d_conv || 0;
+            data.mamba_expand = selectedConfig.mamba_expand || 0;
+            data.mamba_dt_rank = selectedConfig.mamba_dt_rank || 0;
+            data.encoder = selectedConfig.encoder || null;
+            data.vision_config = selectedConfig.vision_config || null;
+            data.image_seq_length = selectedConfig.image_seq_length || 0;
+            data.num_images = selectedConfig.vision_config ? 1 : 0;
+        }
 
         console.log("Sending calculation request:", data);
 